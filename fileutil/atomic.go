@@ -0,0 +1,112 @@
+package fileutil
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrAtomicWriterClosed表示AtomicWriter已经Commit或者Abort
+	ErrAtomicWriterClosed = errors.New("fileutil: atomic writer already closed")
+)
+
+// WriteFileAtomic以原子方式替换filename的内容
+// 数据先写入同目录下的临时文件并fsync，再rename覆盖目标文件，最后fsync父目录保证rename持久化
+// 任意时刻崩溃，filename要么是旧内容要么是新内容
+func WriteFileAtomic(filename string, data []byte, perm os.FileMode) error {
+	w, err := NewAtomicWriter(filename, perm)
+	if err != nil {
+		return err
+	}
+
+	n, err := w.Write(data)
+	if err == nil && n < len(data) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		w.Abort()
+		return err
+	}
+	return w.Commit()
+}
+
+// AtomicWriter流式写入临时文件，Commit时原子替换目标文件
+// Commit之前目标文件保持不变；Abort丢弃已写入的数据
+type AtomicWriter struct {
+	f    *os.File
+	path string
+	done bool
+}
+
+// NewAtomicWriter在filename所在目录下创建临时文件
+func NewAtomicWriter(filename string, perm os.FileMode) (*AtomicWriter, error) {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	// TempFile固定使用0600创建
+	if err = f.Chmod(perm); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, err
+	}
+	return &AtomicWriter{f: f, path: filename}, nil
+}
+
+func (w *AtomicWriter) Write(p []byte) (int, error) {
+	if w.done {
+		return 0, ErrAtomicWriterClosed
+	}
+	return w.f.Write(p)
+}
+
+// Commit fsync临时文件，rename到目标文件并fsync父目录
+// 出错时临时文件被删除，目标文件保持原内容
+func (w *AtomicWriter) Commit() error {
+	if w.done {
+		return ErrAtomicWriterClosed
+	}
+	w.done = true
+
+	tmp := w.f.Name()
+	err := Fsync(w.f)
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, w.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return FsyncDir(filepath.Dir(w.path))
+}
+
+// Abort关闭并删除临时文件
+func (w *AtomicWriter) Abort() error {
+	if w.done {
+		return ErrAtomicWriterClosed
+	}
+	w.done = true
+
+	w.f.Close()
+	return os.Remove(w.f.Name())
+}
+
+// FsyncDir fsync目录本身，使目录项的变更(创建/rename/删除)持久化
+func FsyncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return Fsync(d)
+}
@@ -0,0 +1,73 @@
+package fileutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "conf")
+
+	for _, data := range [][]byte{[]byte("first"), []byte("second")} {
+		if err = WriteFileAtomic(path, data, FileMode); err != nil {
+			t.Fatal(err)
+		}
+		b, rerr := ioutil.ReadFile(path)
+		if rerr != nil {
+			t.Fatal(rerr)
+		}
+		if !bytes.Equal(b, data) {
+			t.Errorf("data = %q, want %q", b, data)
+		}
+	}
+
+	names, err := ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 {
+		t.Errorf("names = %v, want only %q", names, "conf")
+	}
+}
+
+func TestAtomicWriterAbort(t *testing.T) {
+	dir, err := ioutil.TempDir("", "atomic")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "conf")
+
+	if err = WriteFileAtomic(path, []byte("old"), FileMode); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewAtomicWriter(path, FileMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("new")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Abort(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Commit(); err != ErrAtomicWriterClosed {
+		t.Errorf("err = %v, want %v", err, ErrAtomicWriterClosed)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "old" {
+		t.Errorf("data = %q, want %q", b, "old")
+	}
+}
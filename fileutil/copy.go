@@ -0,0 +1,196 @@
+package fileutil
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// 每次copy_file_range/用户态拷贝的大小，同时也是进度回调的粒度
+const copyChunkBytes = 4 * 1024 * 1024
+
+// CopyOptions配置CopyFile/CopyTree
+type CopyOptions struct {
+	// Sync为true时fsync每个拷贝的文件与目录
+	Sync bool
+	// Progress在每拷贝一块数据后被调用，copied与total为累计的byte数
+	Progress func(copied, total int64)
+}
+
+// CopyFile将src拷贝到dst，dst已存在时被替换，权限与修改时间与src保持一致
+// 数据先写入dst所在目录下的临时文件再rename，dst是src本身或者其硬链接时不会破坏src
+// 依次尝试：FICLONE reflink(btrfs/xfs等支持写时复制的文件系统，不占用额外空间)，
+// copy_file_range(内核态拷贝)，用户态拷贝
+func CopyFile(src, dst string, opts *CopyOptions) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	c := newCopier(opts, fi.Size())
+	if err = c.copyFile(src, dst, fi); err != nil {
+		return err
+	}
+	if c.sync {
+		return FsyncDir(filepath.Dir(dst))
+	}
+	return nil
+}
+
+// CopyTree将src目录树拷贝到dst，dst必须不存在
+// 普通文件通过CopyFile拷贝，符号链接被重建，其他特殊文件被忽略
+func CopyTree(src, dst string, opts *CopyOptions) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("fileutil: copy destination %q already exists", dst)
+	}
+
+	var total int64
+	if opts != nil && opts.Progress != nil {
+		err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err == nil && info.Mode().IsRegular() {
+				total += info.Size()
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	c := newCopier(opts, total)
+
+	var dirs []string
+	dirInfos := make(map[string]os.FileInfo)
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			dirs = append(dirs, target)
+			dirInfos[target] = info
+			return os.MkdirAll(target, mode.Perm()|0700)
+		case mode.IsRegular():
+			return c.copyFile(path, target, info)
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			plog.Warningf("skipped copying special file %s", path)
+			return nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// 目录内容写完之后自底向上恢复权限与修改时间
+	for i := len(dirs) - 1; i >= 0; i-- {
+		d, info := dirs[i], dirInfos[dirs[i]]
+		if c.sync {
+			if err = FsyncDir(d); err != nil {
+				return err
+			}
+		}
+		if err = os.Chmod(d, info.Mode().Perm()); err != nil {
+			return err
+		}
+		if err = os.Chtimes(d, info.ModTime(), info.ModTime()); err != nil {
+			return err
+		}
+	}
+	if c.sync {
+		return FsyncDir(filepath.Dir(filepath.Clean(dst)))
+	}
+	return nil
+}
+
+type copier struct {
+	sync     bool
+	progress func(copied, total int64)
+	copied   int64
+	total    int64
+}
+
+func newCopier(opts *CopyOptions, total int64) *copier {
+	c := &copier{total: total}
+	if opts != nil {
+		c.sync, c.progress = opts.Sync, opts.Progress
+	}
+	return c
+}
+
+func (c *copier) report(n int64) {
+	c.copied += n
+	if c.progress != nil {
+		c.progress(c.copied, c.total)
+	}
+}
+
+func (c *copier) copyFile(src, dst string, fi os.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	// 不能直接以O_TRUNC打开dst: dst与src为同一个inode时会在读取之前截断src
+	dir, base := filepath.Split(dst)
+	if dir == "" {
+		dir = "."
+	}
+	out, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	err = c.copyContents(out, in, fi.Size())
+	if err == nil {
+		// TempFile固定使用0600创建
+		err = out.Chmod(fi.Mode().Perm())
+	}
+	if err == nil && c.sync {
+		err = Fsync(out)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chtimes(out.Name(), fi.ModTime(), fi.ModTime())
+	}
+	if err == nil {
+		err = os.Rename(out.Name(), dst)
+	}
+	if err != nil {
+		os.Remove(out.Name())
+	}
+	return err
+}
+
+func (c *copier) copyContents(out, in *os.File, size int64) error {
+	if reflink(out, in) == nil {
+		c.report(size)
+		return nil
+	}
+	// os.File.ReadFrom在支持时使用copy_file_range/sendfile，否则退化为用户态拷贝
+	for {
+		n, err := out.ReadFrom(&io.LimitedReader{R: in, N: copyChunkBytes})
+		if n > 0 {
+			c.report(n)
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,20 @@
+// +build linux
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// 参见 ioctl_ficlone(2)
+const ficlone = 0x40049409
+
+// reflink使dst与src共享数据块
+func reflink(dst, src *os.File) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, dst.Fd(), ficlone, src.Fd())
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package fileutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCopyFileKeepsModeAndMtime(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	data := bytes.Repeat([]byte("0123456789abcdef"), copyChunkBytes/16+100)
+	if err := ioutil.WriteFile(src, data, 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(src, 0640); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2020, 5, 6, 7, 8, 9, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	// dst已存在时被覆盖
+	if err := ioutil.WriteFile(dst, []byte("old content that is longer"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var last, calls int64
+	err := CopyFile(src, dst, &CopyOptions{Sync: true, Progress: func(copied, total int64) {
+		if total != int64(len(data)) || copied < last {
+			t.Errorf("progress %d/%d after %d", copied, total, last)
+		}
+		last = copied
+		calls++
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != int64(len(data)) || calls == 0 {
+		t.Errorf("final progress = %d in %d calls, want %d", last, calls, len(data))
+	}
+
+	got, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("content mismatch")
+	}
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want %v", fi.Mode().Perm(), os.FileMode(0640))
+	}
+	if !fi.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", fi.ModTime(), mtime)
+	}
+}
+
+func TestCopyTree(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	writeTree(t, src, map[string]string{"a": "alpha", "sub/b": "beta"})
+	if err := os.Symlink("a", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "dst")
+	if err := CopyTree(src, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := CopyTree(src, dst, nil); err == nil {
+		t.Error("CopyTree to existing dst: err = nil")
+	}
+	manifest, err := Manifest(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := VerifyManifest(dst, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Clean() {
+		t.Errorf("diff = %+v", diff)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "link")); err != nil || link != "a" {
+		t.Errorf("link = %q, %v", link, err)
+	}
+}
+
+func TestCopyFileOntoItself(t *testing.T) {
+	dir := t.TempDir()
+	src, link := filepath.Join(dir, "src"), filepath.Join(dir, "link")
+	data := []byte("must survive")
+	if err := ioutil.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(src, link); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, dst := range []string{src, link} {
+		if err := CopyFile(src, dst, nil); err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range []string{src, link} {
+			if got, err := ioutil.ReadFile(p); err != nil || !bytes.Equal(got, data) {
+				t.Fatalf("after copying onto %s: %s = %q, %v", dst, p, got, err)
+			}
+		}
+	}
+	if names, _ := ReadDir(dir); len(names) != 2 {
+		t.Errorf("files = %v, want no leftover temporary files", names)
+	}
+}
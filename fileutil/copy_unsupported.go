@@ -0,0 +1,12 @@
+// +build !linux
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+)
+
+func reflink(dst, src *os.File) error {
+	return errors.New("fileutil: reflink is not supported on this platform")
+}
@@ -0,0 +1,263 @@
+package fileutil
+
+import (
+	"io"
+	"os"
+	"unsafe"
+)
+
+const (
+	// 无法获取文件系统块大小时使用的对齐值
+	defaultDirectAlign = 4096
+	// bounce buffer大小
+	directBufferBytes = 1024 * 1024
+)
+
+// AlignedBlock分配一块长度为size、起始地址按align对齐的内存，align必须是2的幂
+func AlignedBlock(size, align int) []byte {
+	if size == 0 {
+		return nil
+	}
+	b := make([]byte, size+align)
+	off := 0
+	if rem := int(uintptr(unsafe.Pointer(&b[0])) & uintptr(align-1)); rem != 0 {
+		off = align - rem
+	}
+	return b[off : off+size : off+size]
+}
+
+func isAligned(b []byte, align int) bool {
+	return len(b) == 0 || uintptr(unsafe.Pointer(&b[0]))&uintptr(align-1) == 0
+}
+
+// DirectFile是以O_DIRECT打开的文件，绕过page cache顺序写入
+// O_DIRECT要求偏移、长度与内存地址均按Alignment对齐，DirectFile通过对齐的bounce buffer满足该要求：
+// 末尾不足一个块的数据补0写入，同时保留在内存中，下一次写入时与新数据合并重写该块
+// 补0使文件超出逻辑大小时，Sync与Close将文件截断回逻辑大小；预分配的文件不受影响
+// 文件系统不支持O_DIRECT(例如tmpfs)时自动退化为普通的buffered I/O，Direct返回false
+// 可以作为ioutil.PageWriter底层的io.Writer，页大小取Alignment的整数倍时绝大多数写入都是整块写入
+type DirectFile struct {
+	f      *os.File
+	direct bool
+	align  int
+
+	buf    []byte // 对齐的bounce buffer
+	tail   []byte // 当前末尾不完整块中已写入的数据
+	off    int64  // 下一次写入的逻辑偏移
+	size   int64  // 文件的逻辑大小
+	padEnd int64  // 补0写入使文件扩展到的位置，大于size时需要截断
+}
+
+// OpenDirect以O_DIRECT打开文件，flag中的O_APPEND被转换为从文件末尾开始写入
+// 合并不完整块时需要读取文件，因此O_WRONLY被转换为O_RDWR
+func OpenDirect(path string, flag int, perm os.FileMode) (*DirectFile, error) {
+	appendMode := flag&os.O_APPEND != 0
+	flag &^= os.O_APPEND
+	if flag&os.O_WRONLY != 0 {
+		flag = flag&^os.O_WRONLY | os.O_RDWR
+	}
+
+	f, direct, err := openDirect(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	d := &DirectFile{f: f, direct: direct, align: directAlign(f), size: fi.Size()}
+	if appendMode {
+		if _, err = d.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// Direct表示当前是否真正使用O_DIRECT
+func (d *DirectFile) Direct() bool { return d.direct }
+
+// Alignment返回O_DIRECT要求的对齐字节数
+func (d *DirectFile) Alignment() int { return d.align }
+
+// File返回底层文件，用于Fdatasync/Preallocate等操作；不要直接通过它写入
+func (d *DirectFile) File() *os.File { return d.f }
+
+func (d *DirectFile) Name() string { return d.f.Name() }
+
+// Seek设置下一次写入的偏移 不完整块中已有的数据会被读入内存
+func (d *DirectFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += d.off
+	case io.SeekEnd:
+		if !d.direct {
+			fi, err := d.f.Stat()
+			if err != nil {
+				return 0, err
+			}
+			d.size = fi.Size()
+		}
+		offset += d.size
+	}
+	if offset < 0 {
+		return 0, os.ErrInvalid
+	}
+	if !d.direct {
+		return d.f.Seek(offset, io.SeekStart)
+	}
+
+	if offset > d.size {
+		d.size = offset
+	}
+	head := int(offset % int64(d.align))
+	if head == 0 {
+		d.off, d.tail = offset, d.tail[:0]
+		return offset, nil
+	}
+	blk := AlignedBlock(d.align, d.align)
+	n, err := d.f.ReadAt(blk, offset-int64(head))
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if n < head {
+		// 块中超出文件末尾的部分视为0
+		for i := n; i < head; i++ {
+			blk[i] = 0
+		}
+	}
+	d.off, d.tail = offset, append(d.tail[:0], blk[:head]...)
+	return offset, nil
+}
+
+func (d *DirectFile) Write(p []byte) (int, error) {
+	if !d.direct {
+		return d.f.Write(p)
+	}
+
+	written := 0
+	for len(p) > 0 {
+		n, err := d.writeChunk(p)
+		written += n
+		if err == errDirectUnsupported {
+			// 写入时才发现不支持O_DIRECT，切换为buffered I/O继续写入
+			plog.Warningf("O_DIRECT rejected on %s, falling back to buffered I/O", d.f.Name())
+			if err = d.disableDirect(); err != nil {
+				return written, err
+			}
+			n, err = d.f.Write(p[n:])
+			return written + n, err
+		}
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// writeChunk将不完整块与p的前缀合并写入，返回写入的p中的字节数
+func (d *DirectFile) writeChunk(p []byte) (int, error) {
+	head := len(d.tail)
+	blockOff := d.off - int64(head)
+
+	// 已对齐且长度为整块时直接写入，避免拷贝
+	if head == 0 && len(p) >= d.align && isAligned(p, d.align) {
+		n := len(p) / d.align * d.align
+		if _, err := d.pwrite(p[:n], blockOff); err != nil {
+			return 0, err
+		}
+		d.off += int64(n)
+		d.grow(d.off, d.off)
+		return n, nil
+	}
+
+	if d.buf == nil {
+		d.buf = AlignedBlock(directBufferBytes, d.align)
+	}
+	n := copy(d.buf[head:], p)
+	copy(d.buf, d.tail)
+	total := head + n
+	padded := (total + d.align - 1) / d.align * d.align
+	for i := total; i < padded; i++ {
+		d.buf[i] = 0
+	}
+	if _, err := d.pwrite(d.buf[:padded], blockOff); err != nil {
+		return 0, err
+	}
+	full := total / d.align * d.align
+	d.tail = append(d.tail[:0], d.buf[full:total]...)
+	d.off += int64(n)
+	d.grow(d.off, blockOff+int64(padded))
+	return n, nil
+}
+
+// grow记录写入后的逻辑大小与补0写入的结束位置
+func (d *DirectFile) grow(end, physEnd int64) {
+	if end > d.size {
+		d.size = end
+	}
+	if physEnd > d.padEnd {
+		d.padEnd = physEnd
+	}
+}
+
+// trimPadding在补0写入使文件超出逻辑大小时截断文件
+// 文件大小与补0写入的结束位置不一致时(例如之后被Preallocate扩展)保持不变
+func (d *DirectFile) trimPadding() error {
+	if d.padEnd <= d.size {
+		return nil
+	}
+	fi, err := d.f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() == d.padEnd {
+		if err = d.f.Truncate(d.size); err != nil {
+			return err
+		}
+	}
+	d.padEnd = 0
+	return nil
+}
+
+func (d *DirectFile) pwrite(b []byte, off int64) (int, error) {
+	n, err := d.f.WriteAt(b, off)
+	if isDirectUnsupported(err) {
+		return n, errDirectUnsupported
+	}
+	return n, err
+}
+
+// disableDirect关闭O_DIRECT并将文件偏移定位到逻辑偏移
+func (d *DirectFile) disableDirect() error {
+	if err := clearDirectFlag(d.f); err != nil {
+		return err
+	}
+	if err := d.trimPadding(); err != nil {
+		return err
+	}
+	d.direct = false
+	d.tail = nil
+	_, err := d.f.Seek(d.off, io.SeekStart)
+	return err
+}
+
+// Sync截断补0的部分后调用Fdatasync持久化已写入的数据
+func (d *DirectFile) Sync() error {
+	if err := d.trimPadding(); err != nil {
+		return err
+	}
+	return Fdatasync(d.f)
+}
+
+// Close截断补0的部分后关闭文件
+func (d *DirectFile) Close() error {
+	err := d.trimPadding()
+	if cerr := d.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+// +build linux
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errDirectUnsupported = errors.New("fileutil: O_DIRECT not supported")
+
+func openDirect(path string, flag int, perm os.FileMode) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, flag|syscall.O_DIRECT, perm)
+	if err == nil {
+		return f, true, nil
+	}
+	if !isDirectUnsupported(err) {
+		return nil, false, err
+	}
+	plog.Warningf("O_DIRECT rejected on %s (%v), falling back to buffered I/O", path, err)
+	f, err = os.OpenFile(path, flag, perm)
+	return f, false, err
+}
+
+func isDirectUnsupported(err error) bool {
+	if perr, ok := err.(*os.PathError); ok {
+		err = perr.Err
+	}
+	return err == syscall.EINVAL
+}
+
+// directAlign使用文件系统块大小作为对齐值
+func directAlign(f *os.File) int {
+	var st syscall.Statfs_t
+	if err := syscall.Fstatfs(int(f.Fd()), &st); err != nil || st.Bsize <= 0 || st.Bsize&(st.Bsize-1) != 0 {
+		return defaultDirectAlign
+	}
+	return int(st.Bsize)
+}
+
+func clearDirectFlag(f *os.File) error {
+	fd := f.Fd()
+	flags, _, errno := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFL, 0)
+	if errno != 0 {
+		return errno
+	}
+	_, _, errno = syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_SETFL, flags&^syscall.O_DIRECT)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package fileutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirectFileLogicalSize(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "direct")
+	d, err := OpenDirect(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FileMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.Write([]byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContent(t, p, []byte("0123456789"))
+	if _, err = d.Write([]byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContent(t, p, []byte("0123456789abc"))
+
+	// 以O_APPEND重新打开，从逻辑末尾继续写入
+	if d, err = OpenDirect(p, os.O_WRONLY|os.O_APPEND, FileMode); err != nil {
+		t.Fatal(err)
+	}
+	big := bytes.Repeat([]byte("x"), 3*d.Alignment()+7)
+	if _, err = d.Write(big); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileContent(t, p, append([]byte("0123456789abc"), big...))
+}
+
+func TestDirectFilePreallocated(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "direct")
+	d, err := OpenDirect(p, os.O_RDWR|os.O_CREATE, FileMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(4 * d.Alignment())
+	if err = Preallocate(d.File(), size, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = d.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != size {
+		t.Errorf("size = %d, want %d", fi.Size(), size)
+	}
+}
+
+func checkFileContent(t *testing.T, p string, want []byte) {
+	t.Helper()
+	got, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("content = %d bytes, want %d bytes %q", len(got), len(want), want)
+	}
+}
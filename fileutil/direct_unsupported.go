@@ -0,0 +1,21 @@
+// +build !linux
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+)
+
+var errDirectUnsupported = errors.New("fileutil: O_DIRECT not supported")
+
+func openDirect(path string, flag int, perm os.FileMode) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, flag, perm)
+	return f, false, err
+}
+
+func isDirectUnsupported(err error) bool { return false }
+
+func directAlign(f *os.File) int { return defaultDirectAlign }
+
+func clearDirectFlag(f *os.File) error { return nil }
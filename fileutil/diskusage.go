@@ -0,0 +1,86 @@
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var (
+	// ErrInsufficientSpace表示可用空间低于SpaceGuard的预留值
+	ErrInsufficientSpace = errors.New("fileutil: insufficient disk space")
+)
+
+// DiskUsageStat是文件系统容量信息，单位为byte
+// Free包含仅root可用的预留块；Available为普通用户可用的空间
+type DiskUsageStat struct {
+	Total      uint64
+	Free       uint64
+	Available  uint64
+	Inodes     uint64
+	InodesFree uint64
+}
+
+// Used返回已使用的byte数
+func (s *DiskUsageStat) Used() uint64 { return s.Total - s.Free }
+
+// SpaceGuard在Preallocate/TouchDirAll之前检查目标文件系统的可用空间
+// 操作完成后可用空间低于Reserve时拒绝执行
+type SpaceGuard struct {
+	Reserve uint64
+}
+
+// NewSpaceGuard创建预留reserve byte的SpaceGuard
+func NewSpaceGuard(reserve uint64) *SpaceGuard {
+	return &SpaceGuard{Reserve: reserve}
+}
+
+// Check检查path所在文件系统在再占用need byte之后是否依然满足预留
+func (g *SpaceGuard) Check(path string, need uint64) error {
+	du, err := DiskUsage(path)
+	if err != nil {
+		return err
+	}
+	if du.Available < need || du.Available-need < g.Reserve {
+		return fmt.Errorf("%w on %s: available %d, need %d, reserve %d",
+			ErrInsufficientSpace, path, du.Available, need, g.Reserve)
+	}
+	return nil
+}
+
+// Preallocate在空间满足预留时调用Preallocate
+func (g *SpaceGuard) Preallocate(f *os.File, sizeInBytes int64, extendFile bool) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	var need uint64
+	if sizeInBytes > fi.Size() {
+		need = uint64(sizeInBytes - fi.Size())
+	}
+	if err = g.Check(f.Name(), need); err != nil {
+		return err
+	}
+	return Preallocate(f, sizeInBytes, extendFile)
+}
+
+// TouchDirAll在空间满足预留时调用TouchDirAll
+// dir尚未创建时检查其最近的已存在祖先目录所在的文件系统
+func (g *SpaceGuard) TouchDirAll(dir string) error {
+	p := filepath.Clean(dir)
+	for {
+		if _, err := os.Stat(p); err == nil {
+			break
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			break
+		}
+		p = parent
+	}
+	if err := g.Check(p, 0); err != nil {
+		return err
+	}
+	return TouchDirAll(dir)
+}
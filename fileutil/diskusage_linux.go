@@ -0,0 +1,52 @@
+// +build linux
+
+package fileutil
+
+import (
+	"fmt"
+	"syscall"
+)
+
+// 参见 statfs(2) 以及 linux/magic.h
+// ext2/ext3/ext4共用同一个magic，统一识别为ext4
+var fsMagics = map[int64]string{
+	0xEF53:     "ext4",
+	0x58465342: "xfs",
+	0x9123683E: "btrfs",
+	0x01021994: "tmpfs",
+	0x794C7630: "overlay",
+	0x6969:     "nfs",
+	0x2FC12FC1: "zfs",
+	0xF2F52010: "f2fs",
+	0x65735546: "fuse",
+}
+
+// DiskUsage返回path所在文件系统的容量信息
+func DiskUsage(path string) (*DiskUsageStat, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return nil, err
+	}
+	bsize := uint64(st.Bsize)
+	return &DiskUsageStat{
+		Total:      st.Blocks * bsize,
+		Free:       st.Bfree * bsize,
+		Available:  st.Bavail * bsize,
+		Inodes:     st.Files,
+		InodesFree: st.Ffree,
+	}, nil
+}
+
+// FsType返回path所在文件系统的类型，例如ext4/xfs/btrfs/tmpfs/overlay
+// 无法识别时返回"unknown(0x<magic>)"
+func FsType(path string) (string, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return "", err
+	}
+	magic := int64(st.Type) & 0xFFFFFFFF
+	if name, ok := fsMagics[magic]; ok {
+		return name, nil
+	}
+	return fmt.Sprintf("unknown(%#x)", magic), nil
+}
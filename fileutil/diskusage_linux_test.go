@@ -0,0 +1,30 @@
+// +build linux
+
+package fileutil
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestFsType(t *testing.T) {
+	typ, err := FsType(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ == "" {
+		t.Error("empty filesystem type")
+	}
+
+	// procfs不在fsMagics中
+	if typ, err = FsType("/proc"); err != nil {
+		t.Skip(err)
+	}
+	if typ != "unknown(0x9fa0)" {
+		t.Errorf("/proc type = %q, want %q", typ, "unknown(0x9fa0)")
+	}
+
+	if _, err = FsType(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing path")
+	}
+}
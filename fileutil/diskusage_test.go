@@ -0,0 +1,65 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiskUsage(t *testing.T) {
+	du, err := DiskUsage(t.TempDir())
+	if err != nil {
+		t.Skip(err)
+	}
+	if du.Total == 0 || du.Free > du.Total || du.Available > du.Free {
+		t.Errorf("unexpected usage %+v", du)
+	}
+	if du.Used() != du.Total-du.Free {
+		t.Errorf("used = %d, want %d", du.Used(), du.Total-du.Free)
+	}
+}
+
+func TestSpaceGuardReserve(t *testing.T) {
+	dir := t.TempDir()
+	du, err := DiskUsage(dir)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	if err = NewSpaceGuard(0).Check(dir, 4096); err != nil {
+		t.Errorf("reserve 0: err = %v", err)
+	}
+	// 预留值超过可用空间(留出余量，避免测试期间可用空间变化)
+	g := NewSpaceGuard(du.Available + 1<<30)
+	if err = g.Check(dir, 0); !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("Check err = %v, want %v", err, ErrInsufficientSpace)
+	}
+	// 需要的空间超过可用空间
+	if err = NewSpaceGuard(0).Check(dir, du.Available+1<<30); !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("Check err = %v, want %v", err, ErrInsufficientSpace)
+	}
+
+	f, err := os.Create(filepath.Join(dir, "prealloc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err = g.Preallocate(f, 1<<20, true); !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("Preallocate err = %v, want %v", err, ErrInsufficientSpace)
+	}
+	if fi, _ := f.Stat(); fi.Size() != 0 {
+		t.Errorf("size = %d after refused Preallocate", fi.Size())
+	}
+	if err = NewSpaceGuard(0).Preallocate(f, 1<<20, true); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := filepath.Join(dir, "a", "b")
+	if err = g.TouchDirAll(sub); !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("TouchDirAll err = %v, want %v", err, ErrInsufficientSpace)
+	}
+	if _, err = os.Stat(sub); !os.IsNotExist(err) {
+		t.Errorf("dir created despite refusal: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+// +build !linux,!darwin
+
+package fileutil
+
+import "errors"
+
+var errDiskUsageUnsupported = errors.New("fileutil: disk usage is not supported on this platform")
+
+func DiskUsage(path string) (*DiskUsageStat, error) {
+	return nil, errDiskUsageUnsupported
+}
+
+func FsType(path string) (string, error) {
+	return "", errDiskUsageUnsupported
+}
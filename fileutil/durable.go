@@ -0,0 +1,73 @@
+package fileutil
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DurableMkdirAll与os.MkdirAll相同，但会fsync每个新建目录的父目录
+// 保证掉电之后新建的目录依然存在
+func DurableMkdirAll(path string, perm os.FileMode) error {
+	p := filepath.Clean(path)
+	// 自底向上收集尚不存在的目录
+	var created []string
+	for {
+		if _, err := os.Stat(p); err == nil {
+			break
+		}
+		created = append(created, p)
+		parent := filepath.Dir(p)
+		if parent == p {
+			break
+		}
+		p = parent
+	}
+	if err := os.MkdirAll(path, perm); err != nil {
+		return err
+	}
+	for _, d := range created {
+		if err := FsyncDir(filepath.Dir(d)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DurableRename与os.Rename相同，但会fsync新旧路径所在的目录
+func DurableRename(oldpath, newpath string) error {
+	if err := os.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	newDir, oldDir := filepath.Dir(newpath), filepath.Dir(oldpath)
+	if err := FsyncDir(newDir); err != nil {
+		return err
+	}
+	if filepath.Clean(oldDir) != filepath.Clean(newDir) {
+		return FsyncDir(oldDir)
+	}
+	return nil
+}
+
+// DurableRemoveAll与os.RemoveAll相同，但会fsync path的父目录
+func DurableRemoveAll(path string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	return FsyncDir(filepath.Dir(filepath.Clean(path)))
+}
+
+// DurableTouchDirAll与TouchDirAll相同，但通过DurableMkdirAll创建目录
+func DurableTouchDirAll(dir string) error {
+	if err := DurableMkdirAll(dir, DirMode); err != nil {
+		return err
+	}
+	return IsDirWriteable(dir)
+}
+
+// DurableCreateDirAll与CreateDirAll相同，但通过DurableMkdirAll创建目录
+func DurableCreateDirAll(dir string) error {
+	if err := DurableMkdirAll(dir, DirMode); err != nil {
+		return err
+	}
+	return CreateDirAll(dir)
+}
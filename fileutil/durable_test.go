@@ -0,0 +1,67 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDurableMkdirAll(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "a", "b", "c")
+	if err := DurableMkdirAll(p, DirMode); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{filepath.Join(dir, "a"), filepath.Join(dir, "a", "b"), p} {
+		fi, err := os.Stat(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !fi.IsDir() || fi.Mode().Perm() != DirMode {
+			t.Errorf("%s: mode = %v, want dir %v", d, fi.Mode(), os.FileMode(DirMode))
+		}
+	}
+	// 已存在时不做处理
+	if err := DurableMkdirAll(p, DirMode); err != nil {
+		t.Errorf("existing dir: err = %v", err)
+	}
+
+	f := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(f, nil, FileMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := DurableMkdirAll(filepath.Join(f, "sub"), DirMode); err == nil {
+		t.Error("mkdir under a file: err = nil")
+	}
+}
+
+func TestDurableRenameRemoveAll(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "x", "src"), filepath.Join(dir, "y", "dst")
+	if err := DurableMkdirAll(filepath.Dir(src), DirMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := DurableMkdirAll(filepath.Dir(dst), DirMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(src, []byte("data"), FileMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := DurableRename(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(dst); err != nil || string(b) != "data" {
+		t.Errorf("dst = %q, %v", b, err)
+	}
+
+	if err := DurableRemoveAll(filepath.Join(dir, "y")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "y")); !os.IsNotExist(err) {
+		t.Errorf("stat removed dir: %v", err)
+	}
+	if err := FsyncDir(filepath.Join(dir, "missing")); !os.IsNotExist(err) {
+		t.Errorf("FsyncDir(missing) = %v", err)
+	}
+}
@@ -0,0 +1,332 @@
+package fileutil
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FaultOp标识可注入故障的操作类型
+type FaultOp int
+
+const (
+	FaultOpen FaultOp = iota
+	FaultWrite
+	FaultSync
+	FaultTruncate
+	FaultRemove
+	FaultRename
+	FaultMkdir
+)
+
+var (
+	// ErrCrashed表示文件句柄在FaultFS.Crash之前打开，已经失效
+	ErrCrashed = errors.New("fileutil: file handle invalidated by simulated crash")
+)
+
+// FaultFS包装FS，可按需注入故障:
+// 1、Inject使指定操作返回给定错误，例如syscall.ENOSPC、syscall.EIO
+// 2、SetShortWrite使每次写入只写一半数据并返回io.ErrShortWrite
+// 3、Crash模拟掉电：所有未fsync的文件内容回退到最近一次fsync时的状态，自上次fsync以来新建的文件被删除
+// 目录项变更(rename/remove/mkdir)视为立即持久化
+type FaultFS struct {
+	fs FS
+
+	mu         sync.Mutex
+	faults     map[FaultOp]error
+	shortWrite bool
+	// 记录自上次fsync以来被修改的文件在修改前的内容
+	unsynced map[string]*syncedState
+	gen      int
+}
+
+type syncedState struct {
+	existed bool
+	data    []byte
+}
+
+// NewFaultFS包装fs
+func NewFaultFS(fs FS) *FaultFS {
+	return &FaultFS{
+		fs:       fs,
+		faults:   make(map[FaultOp]error),
+		unsynced: make(map[string]*syncedState),
+	}
+}
+
+// Inject使op类型的操作在Clear之前一直返回err
+func (ffs *FaultFS) Inject(op FaultOp, err error) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	ffs.faults[op] = err
+}
+
+// Clear移除op上注入的故障
+func (ffs *FaultFS) Clear(op FaultOp) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	delete(ffs.faults, op)
+}
+
+// SetShortWrite开启或关闭短写
+func (ffs *FaultFS) SetShortWrite(on bool) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	ffs.shortWrite = on
+}
+
+// Crash丢弃所有未fsync的数据，并使之前打开的文件句柄失效
+func (ffs *FaultFS) Crash() error {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+
+	ffs.gen++
+	for p, st := range ffs.unsynced {
+		if err := ffs.restore(p, st); err != nil {
+			return err
+		}
+		delete(ffs.unsynced, p)
+	}
+	return nil
+}
+
+func (ffs *FaultFS) restore(p string, st *syncedState) error {
+	if !st.existed {
+		if err := ffs.fs.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	f, err := ffs.fs.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FileMode)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(st.data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (ffs *FaultFS) fault(op FaultOp) error {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	return ffs.faults[op]
+}
+
+// snapshot在文件第一次被修改前记录其已持久化的内容
+func (ffs *FaultFS) snapshot(p string) error {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+
+	if _, ok := ffs.unsynced[p]; ok {
+		return nil
+	}
+	st := &syncedState{}
+	f, err := ffs.fs.OpenFile(p, os.O_RDONLY, 0)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		st.existed = true
+		st.data, err = ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	ffs.unsynced[p] = st
+	return nil
+}
+
+func (ffs *FaultFS) synced(p string) {
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	delete(ffs.unsynced, p)
+}
+
+func (ffs *FaultFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	if err := ffs.fault(FaultOpen); err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	p := filepath.Clean(name)
+	if flag&(os.O_CREATE|os.O_TRUNC) != 0 {
+		if err := ffs.snapshot(p); err != nil {
+			return nil, err
+		}
+	}
+	f, err := ffs.fs.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	return &faultFile{File: f, fs: ffs, path: p, gen: ffs.gen}, nil
+}
+
+func (ffs *FaultFS) Stat(name string) (os.FileInfo, error) { return ffs.fs.Stat(name) }
+
+func (ffs *FaultFS) Remove(name string) error {
+	if err := ffs.fault(FaultRemove); err != nil {
+		return &os.PathError{Op: "remove", Path: name, Err: err}
+	}
+	if err := ffs.fs.Remove(name); err != nil {
+		return err
+	}
+	ffs.synced(filepath.Clean(name))
+	return nil
+}
+
+func (ffs *FaultFS) RemoveAll(path string) error {
+	if err := ffs.fault(FaultRemove); err != nil {
+		return &os.PathError{Op: "removeall", Path: path, Err: err}
+	}
+	if err := ffs.fs.RemoveAll(path); err != nil {
+		return err
+	}
+
+	p := filepath.Clean(path)
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	for k := range ffs.unsynced {
+		if k == p || isUnder(k, p) {
+			delete(ffs.unsynced, k)
+		}
+	}
+	return nil
+}
+
+func (ffs *FaultFS) Rename(oldpath, newpath string) error {
+	if err := ffs.fault(FaultRename); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	if err := ffs.fs.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+
+	// rename本身已持久化，未fsync的内容随文件一同迁移
+	op, np := filepath.Clean(oldpath), filepath.Clean(newpath)
+	ffs.mu.Lock()
+	defer ffs.mu.Unlock()
+	delete(ffs.unsynced, np)
+	for k, st := range ffs.unsynced {
+		if k == op {
+			ffs.unsynced[np] = st
+			delete(ffs.unsynced, k)
+		} else if isUnder(k, op) {
+			ffs.unsynced[np+k[len(op):]] = st
+			delete(ffs.unsynced, k)
+		}
+	}
+	return nil
+}
+
+func (ffs *FaultFS) MkdirAll(path string, perm os.FileMode) error {
+	if err := ffs.fault(FaultMkdir); err != nil {
+		return &os.PathError{Op: "mkdir", Path: path, Err: err}
+	}
+	return ffs.fs.MkdirAll(path, perm)
+}
+
+// faultFile包装底层File，在读写前检查注入的故障
+type faultFile struct {
+	File
+	fs   *FaultFS
+	path string
+	gen  int
+}
+
+func (f *faultFile) check() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.gen != f.fs.gen {
+		return &os.PathError{Op: "access", Path: f.Name(), Err: ErrCrashed}
+	}
+	return nil
+}
+
+func (f *faultFile) Read(p []byte) (int, error) {
+	if err := f.check(); err != nil {
+		return 0, err
+	}
+	return f.File.Read(p)
+}
+
+func (f *faultFile) ReadAt(p []byte, off int64) (int, error) {
+	if err := f.check(); err != nil {
+		return 0, err
+	}
+	return f.File.ReadAt(p, off)
+}
+
+func (f *faultFile) Write(p []byte) (int, error) {
+	return f.write(p, func(b []byte) (int, error) { return f.File.Write(b) })
+}
+
+func (f *faultFile) WriteAt(p []byte, off int64) (int, error) {
+	return f.write(p, func(b []byte) (int, error) { return f.File.WriteAt(b, off) })
+}
+
+func (f *faultFile) write(p []byte, w func([]byte) (int, error)) (int, error) {
+	if err := f.check(); err != nil {
+		return 0, err
+	}
+	if err := f.fs.fault(FaultWrite); err != nil {
+		return 0, &os.PathError{Op: "write", Path: f.Name(), Err: err}
+	}
+	if err := f.fs.snapshot(f.path); err != nil {
+		return 0, err
+	}
+
+	f.fs.mu.Lock()
+	short := f.fs.shortWrite
+	f.fs.mu.Unlock()
+	if short && len(p) > 1 {
+		n, err := w(p[:len(p)/2])
+		if err == nil {
+			err = io.ErrShortWrite
+		}
+		return n, err
+	}
+	return w(p)
+}
+
+func (f *faultFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.check(); err != nil {
+		return 0, err
+	}
+	return f.File.Seek(offset, whence)
+}
+
+func (f *faultFile) Truncate(size int64) error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	if err := f.fs.fault(FaultTruncate); err != nil {
+		return &os.PathError{Op: "truncate", Path: f.Name(), Err: err}
+	}
+	if err := f.fs.snapshot(f.path); err != nil {
+		return err
+	}
+	return f.File.Truncate(size)
+}
+
+// Sync失败时数据依然视为未持久化
+func (f *faultFile) Sync() error {
+	if err := f.check(); err != nil {
+		return err
+	}
+	if err := f.fs.fault(FaultSync); err != nil {
+		return &os.PathError{Op: "sync", Path: f.Name(), Err: err}
+	}
+	if err := f.File.Sync(); err != nil {
+		return err
+	}
+	f.fs.synced(f.path)
+	return nil
+}
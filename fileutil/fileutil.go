@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/coreos/pkg/capnslog"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
@@ -25,16 +24,30 @@ var (
 // 目录dir是否具备写权限: 通过写文件或删文件
 // 若是具备 则返回nil
 func IsDirWriteable(dir string) error {
+	return IsDirWriteableFS(OSFS, dir)
+}
+
+// IsDirWriteableFS与IsDirWriteable相同，但操作指定的fs
+func IsDirWriteableFS(fs FS, dir string) error {
 	f := filepath.Join(dir, ".touch")
-	if err := ioutil.WriteFile(f, []byte(""), FileMode); err != nil {
+	w, err := fs.OpenFile(f, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FileMode)
+	if err != nil {
 		return err
 	}
-	return os.Remove(f)
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return fs.Remove(f)
 }
 
 // 返回指定目录下的文件列表默认排序
 func ReadDir(dirpath string) ([]string, error) {
-	dir, err := os.Open(dirpath)
+	return ReadDirFS(OSFS, dirpath)
+}
+
+// ReadDirFS与ReadDir相同，但操作指定的fs
+func ReadDirFS(fs FS, dirpath string) ([]string, error) {
+	dir, err := fs.OpenFile(dirpath, os.O_RDONLY, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -52,20 +65,30 @@ func ReadDir(dirpath string) ([]string, error) {
 // TouchDirAll类似os.MkdirAll.若是对应的目录不存在，则创建并授予0700权限；
 // TouchDirAll确保给定的目录具有writable权限
 func TouchDirAll(dir string) error {
+	return TouchDirAllFS(OSFS, dir)
+}
+
+// TouchDirAllFS与TouchDirAll相同，但操作指定的fs
+func TouchDirAllFS(fs FS, dir string) error {
 	// 若是path本身就是目录，则MkdirAll方法不进行任何操作并返回nil
-	err := os.MkdirAll(dir, DirMode)
+	err := fs.MkdirAll(dir, DirMode)
 	if err != nil {
 		return err
 	}
-	return IsDirWriteable(dir)
+	return IsDirWriteableFS(fs, dir)
 }
 
 // CreateDirAll类似TouchDirAll，若是子目录不为空，则返回error
 func CreateDirAll(dir string) error {
-	err := TouchDirAll(dir)
+	return CreateDirAllFS(OSFS, dir)
+}
+
+// CreateDirAllFS与CreateDirAll相同，但操作指定的fs
+func CreateDirAllFS(fs FS, dir string) error {
+	err := TouchDirAllFS(fs, dir)
 	if err != nil {
 		var ns []string
-		ns, err = ReadDir(dir)
+		ns, err = ReadDirFS(fs, dir)
 		if err != nil {
 			return err
 		}
@@ -82,7 +105,32 @@ func Exist(name string) bool {
 	return err != nil
 }
 
+// ZeroToEnd将文件当前偏移到末尾的区域置0，文件大小与已分配的磁盘块保持不变
+// 完成后偏移恢复到原位置
 func ZeroToEnd(f *os.File) error {
+	off, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	lenf, lerr := f.Seek(0, io.SeekEnd)
+	if lerr != nil {
+		return lerr
+	}
+	if err = ZeroRange(f, off, lenf-off); err != nil {
+		return err
+	}
+
+	_, err = f.Seek(off, io.SeekStart)
+	return err
+}
+
+// ZeroToEndFile与ZeroToEnd相同，f可以是任意FS打开的文件
+// 非*os.File通过truncate+preallocate置0
+func ZeroToEndFile(f File) error {
+	if osf, ok := f.(*os.File); ok {
+		return ZeroToEnd(osf)
+	}
+
 	off, err := f.Seek(0, io.SeekCurrent)
 	if err != nil {
 		return err
@@ -95,7 +143,7 @@ func ZeroToEnd(f *os.File) error {
 		return err
 	}
 
-	if err = Preallocate(f, lenf, true); err != nil {
+	if err = PreallocateFile(f, lenf, true); err != nil {
 		return err
 	}
 
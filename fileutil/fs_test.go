@@ -0,0 +1,148 @@
+package fileutil
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+func TestMemFSDirHelpers(t *testing.T) {
+	fs := NewMemFS()
+	if err := TouchDirAllFS(fs, "/data/wal"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"/data/wal/1.wal", "/data/wal/0.wal"} {
+		f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE, FileMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+
+	names, err := ReadDirFS(fs, "/data/wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"0.wal", "1.wal"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names = %v, want %v", names, want)
+	}
+	if err = CreateDirAllFS(fs, "/data/wal"); err != nil {
+		t.Errorf("err = %v, want nil", err)
+	}
+	if err = fs.Remove("/data/wal"); err == nil {
+		t.Error("removed non-empty directory")
+	}
+}
+
+func TestMemFSZeroToEnd(t *testing.T) {
+	fs := NewMemFS()
+	f, err := fs.OpenFile("seg", os.O_RDWR|os.O_CREATE, FileMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = PreallocateFile(f, 64, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write([]byte("abcdefgh")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if err = ZeroToEndFile(f); err != nil {
+		t.Fatal(err)
+	}
+
+	b := make([]byte, 64)
+	if _, err = f.ReadAt(b, 0); err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, 64)
+	copy(want, "abcd")
+	if !reflect.DeepEqual(b, want) {
+		t.Errorf("data = %q, want %q", b, want)
+	}
+}
+
+func TestFaultFSInject(t *testing.T) {
+	fs := NewFaultFS(NewMemFS())
+	f, err := fs.OpenFile("f", os.O_WRONLY|os.O_CREATE, FileMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs.Inject(FaultWrite, syscall.ENOSPC)
+	if _, err = f.Write([]byte("data")); !isErrno(err, syscall.ENOSPC) {
+		t.Errorf("err = %v, want %v", err, syscall.ENOSPC)
+	}
+	fs.Clear(FaultWrite)
+
+	fs.SetShortWrite(true)
+	if n, werr := f.Write([]byte("data")); werr != io.ErrShortWrite || n != 2 {
+		t.Errorf("n, err = %d, %v, want 2, %v", n, werr, io.ErrShortWrite)
+	}
+	fs.SetShortWrite(false)
+
+	fs.Inject(FaultSync, syscall.EIO)
+	if err = f.Sync(); !isErrno(err, syscall.EIO) {
+		t.Errorf("err = %v, want %v", err, syscall.EIO)
+	}
+}
+
+func TestFaultFSCrash(t *testing.T) {
+	fs := NewFaultFS(NewMemFS())
+	write := func(name, data string, sync bool) {
+		f, err := fs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FileMode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		if _, err = f.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		if sync {
+			if err = f.Sync(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write("synced", "old", true)
+	write("synced", "new", false)
+	write("unsynced", "data", false)
+
+	f, err := fs.OpenFile("synced", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = fs.Crash(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Read(make([]byte, 1)); err == nil {
+		t.Error("read from file opened before crash succeeded")
+	}
+
+	f, err = fs.OpenFile("synced", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "old" {
+		t.Errorf("data = %q, want %q", b, "old")
+	}
+	if _, err = fs.Stat("unsynced"); !os.IsNotExist(err) {
+		t.Errorf("err = %v, want not exist", err)
+	}
+}
+
+func isErrno(err error, errno syscall.Errno) bool {
+	if perr, ok := err.(*os.PathError); ok {
+		err = perr.Err
+	}
+	return err == errno
+}
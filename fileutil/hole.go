@@ -0,0 +1,91 @@
+package fileutil
+
+import (
+	"io"
+	"os"
+)
+
+// Extent是文件中连续的数据区域或者空洞区域
+type Extent struct {
+	Offset int64
+	Length int64
+	Hole   bool
+}
+
+// PunchHole释放[off, off+length)区域占用的磁盘块，文件大小保持不变，读取该区域返回0
+// 文件系统不支持时退化为写0
+func PunchHole(f *os.File, off, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+	return punchHole(f, off, length)
+}
+
+// ZeroRange将[off, off+length)区域置0并保留已分配的磁盘块
+// 文件系统不支持时退化为truncate+preallocate或者写0
+func ZeroRange(f *os.File, off, length int64) error {
+	if length <= 0 {
+		return nil
+	}
+	return zeroRange(f, off, length)
+}
+
+// SparseExtents按偏移顺序返回文件的数据区域与空洞区域
+// 不支持SEEK_DATA/SEEK_HOLE时整个文件视为一个数据区域
+func SparseExtents(f *os.File) ([]Extent, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() == 0 {
+		return nil, nil
+	}
+	return sparseExtents(f, fi.Size())
+}
+
+// zeroRangeFallback在不支持fallocate标志位时置0
+// 区域到达文件末尾时沿用ZeroToEnd原有的truncate+preallocate，否则直接写0
+func zeroRangeFallback(f *os.File, off, length int64) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := fi.Size()
+	if off >= size {
+		return nil
+	}
+	if off+length >= size {
+		if err = f.Truncate(off); err != nil {
+			return err
+		}
+		return Preallocate(f, size, true)
+	}
+
+	buf := make([]byte, 32*1024)
+	for length > 0 {
+		n := int64(len(buf))
+		if n > length {
+			n = length
+		}
+		if _, err = f.WriteAt(buf[:n], off); err != nil {
+			return err
+		}
+		off += n
+		length -= n
+	}
+	return nil
+}
+
+// wholeFileExtent将整个文件视为一个数据区域
+func wholeFileExtent(size int64) []Extent {
+	return []Extent{{Offset: 0, Length: size}}
+}
+
+// 保证Extent相关函数不改变f当前的偏移
+func restoreOffset(f *os.File) (func(), error) {
+	cur, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	return func() { f.Seek(cur, io.SeekStart) }, nil
+}
@@ -0,0 +1,76 @@
+// +build linux
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+)
+
+// 参见 fallocate(2) 与 lseek(2)
+const (
+	fallocFlKeepSize  = 0x01
+	fallocFlPunchHole = 0x02
+	fallocFlZeroRange = 0x10
+
+	seekData = 3
+	seekHole = 4
+)
+
+func punchHole(f *os.File, off, length int64) error {
+	err := syscall.Fallocate(int(f.Fd()), fallocFlPunchHole|fallocFlKeepSize, off, length)
+	if isFallocUnsupported(err) {
+		return zeroRangeFallback(f, off, length)
+	}
+	return err
+}
+
+func zeroRange(f *os.File, off, length int64) error {
+	err := syscall.Fallocate(int(f.Fd()), fallocFlZeroRange|fallocFlKeepSize, off, length)
+	if isFallocUnsupported(err) {
+		return zeroRangeFallback(f, off, length)
+	}
+	return err
+}
+
+func isFallocUnsupported(err error) bool {
+	errno, ok := err.(syscall.Errno)
+	return ok && (errno == syscall.EOPNOTSUPP || errno == syscall.ENOSYS)
+}
+
+func sparseExtents(f *os.File, size int64) ([]Extent, error) {
+	restore, err := restoreOffset(f)
+	if err != nil {
+		return nil, err
+	}
+	defer restore()
+
+	fd := int(f.Fd())
+	var exts []Extent
+	for off := int64(0); off < size; {
+		data, err := syscall.Seek(fd, off, seekData)
+		if err == syscall.ENXIO {
+			// off之后没有数据
+			exts = append(exts, Extent{Offset: off, Length: size - off, Hole: true})
+			break
+		}
+		if err == syscall.EINVAL {
+			return wholeFileExtent(size), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if data > off {
+			exts = append(exts, Extent{Offset: off, Length: data - off, Hole: true})
+		}
+
+		// 文件末尾总是隐式的空洞，因此SEEK_HOLE必定成功
+		hole, err := syscall.Seek(fd, data, seekHole)
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, Extent{Offset: data, Length: hole - data})
+		off = hole
+	}
+	return exts, nil
+}
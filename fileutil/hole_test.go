@@ -0,0 +1,114 @@
+// +build linux
+
+package fileutil
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+const holeTestBlock = 64 * 1024
+
+func createFilledFile(t *testing.T, size int) *os.File {
+	f, err := os.Create(filepath.Join(t.TempDir(), "f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = f.Write(bytes.Repeat([]byte{0xaa}, size)); err != nil {
+		t.Fatal(err)
+	}
+	if err = Fsync(f); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func allocatedBytes(t *testing.T, f *os.File) int64 {
+	var st syscall.Stat_t
+	if err := syscall.Fstat(int(f.Fd()), &st); err != nil {
+		t.Fatal(err)
+	}
+	return st.Blocks * 512
+}
+
+func checkZero(t *testing.T, f *os.File, off, length int64) {
+	t.Helper()
+	b := make([]byte, length)
+	if _, err := f.ReadAt(b, off); err != nil {
+		t.Fatal(err)
+	}
+	if !isZeroBytes(b) {
+		t.Errorf("[%d, %d) not zeroed", off, off+length)
+	}
+}
+
+func isZeroBytes(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPunchHoleSparseExtents(t *testing.T) {
+	f := createFilledFile(t, 3*holeTestBlock)
+	defer f.Close()
+
+	if err := PunchHole(f, holeTestBlock, holeTestBlock); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := f.Stat(); fi.Size() != 3*holeTestBlock {
+		t.Errorf("size = %d, want %d", fi.Size(), 3*holeTestBlock)
+	}
+	checkZero(t, f, holeTestBlock, holeTestBlock)
+
+	exts, err := SparseExtents(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exts) == 1 {
+		t.Skipf("filesystem does not report holes: %+v", exts)
+	}
+	want := []Extent{
+		{Offset: 0, Length: holeTestBlock},
+		{Offset: holeTestBlock, Length: holeTestBlock, Hole: true},
+		{Offset: 2 * holeTestBlock, Length: holeTestBlock},
+	}
+	if len(exts) != len(want) {
+		t.Fatalf("extents = %+v, want %+v", exts, want)
+	}
+	for i := range want {
+		if exts[i] != want[i] {
+			t.Errorf("extent #%d = %+v, want %+v", i, exts[i], want[i])
+		}
+	}
+}
+
+func TestZeroToEndKeepsSizeAndAllocation(t *testing.T) {
+	f := createFilledFile(t, 4*holeTestBlock)
+	defer f.Close()
+	before := allocatedBytes(t, f)
+
+	if _, err := f.Seek(holeTestBlock, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := ZeroToEnd(f); err != nil {
+		t.Fatal(err)
+	}
+
+	if off, _ := f.Seek(0, io.SeekCurrent); off != holeTestBlock {
+		t.Errorf("offset = %d, want %d", off, holeTestBlock)
+	}
+	if fi, _ := f.Stat(); fi.Size() != 4*holeTestBlock {
+		t.Errorf("size = %d, want %d", fi.Size(), 4*holeTestBlock)
+	}
+	checkZero(t, f, holeTestBlock, 3*holeTestBlock)
+	if after := allocatedBytes(t, f); after < before {
+		t.Errorf("allocated %d bytes, was %d", after, before)
+	}
+}
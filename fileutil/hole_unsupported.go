@@ -0,0 +1,17 @@
+// +build !linux
+
+package fileutil
+
+import "os"
+
+func punchHole(f *os.File, off, length int64) error {
+	return zeroRangeFallback(f, off, length)
+}
+
+func zeroRange(f *os.File, off, length int64) error {
+	return zeroRangeFallback(f, off, length)
+}
+
+func sparseExtents(f *os.File, size int64) ([]Extent, error) {
+	return wholeFileExtent(size), nil
+}
@@ -0,0 +1,152 @@
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrLocked表示文件已被其他句柄加锁
+	ErrLocked = errors.New("fileutil: file already locked")
+)
+
+// LockedFile包装已持有排他锁的文件
+// 关闭文件即释放锁
+type LockedFile struct{ *os.File }
+
+// LockHolder记录锁持有者信息
+type LockHolder struct {
+	PID      int
+	Hostname string
+}
+
+func (h *LockHolder) String() string {
+	return fmt.Sprintf("pid %d on %s", h.PID, h.Hostname)
+}
+
+// LockError在TryLockFile获取锁失败时返回，errors.Is(err, ErrLocked)成立
+// 若是加锁时开启了WithHolderInfo，则Holder为当前持有者信息，否则为nil
+type LockError struct {
+	Path   string
+	Holder *LockHolder
+}
+
+func (e *LockError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("%v: %s", ErrLocked, e.Path)
+	}
+	return fmt.Sprintf("%v: %s (held by %s)", ErrLocked, e.Path, e.Holder)
+}
+
+func (e *LockError) Unwrap() error { return ErrLocked }
+
+type lockOptions struct {
+	holderInfo bool
+}
+
+// LockOption配置加锁行为
+type LockOption func(*lockOptions)
+
+// WithHolderInfo在获得锁之后将当前进程的PID/hostname写入锁文件
+// 获取锁失败时则从锁文件读取持有者信息，填充到LockError中
+// 锁文件内容将被覆盖，仅适用于专用的锁文件
+func WithHolderInfo() LockOption {
+	return func(o *lockOptions) { o.holderInfo = true }
+}
+
+// TryLockFile以非阻塞方式对path加排他锁
+// 若是文件已被加锁，则返回*LockError
+func TryLockFile(path string, flag int, perm os.FileMode, opts ...LockOption) (*LockedFile, error) {
+	op := applyLockOptions(opts, &flag)
+	l, err := tryLockFile(path, flag, perm)
+	if err != nil {
+		if err == ErrLocked {
+			lerr := &LockError{Path: path}
+			if op.holderInfo {
+				lerr.Holder, _ = readLockHolder(path)
+			}
+			return nil, lerr
+		}
+		return nil, err
+	}
+	return l, finishLock(l, op)
+}
+
+// LockFile以阻塞方式对path加排他锁，直到获得锁为止
+func LockFile(path string, flag int, perm os.FileMode, opts ...LockOption) (*LockedFile, error) {
+	op := applyLockOptions(opts, &flag)
+	l, err := lockFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return l, finishLock(l, op)
+}
+
+func applyLockOptions(opts []LockOption, flag *int) lockOptions {
+	var op lockOptions
+	for _, opt := range opts {
+		opt(&op)
+	}
+	if op.holderInfo {
+		// 持有者信息由加锁之后写入 在此之前不能截断文件，否则会清掉当前持有者的信息
+		*flag &^= os.O_TRUNC
+		if *flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+			*flag |= os.O_RDWR
+		}
+	}
+	return op
+}
+
+func finishLock(l *LockedFile, op lockOptions) error {
+	if !op.holderInfo {
+		return nil
+	}
+	if err := writeLockHolder(l.File); err != nil {
+		l.Close()
+		return err
+	}
+	return nil
+}
+
+// 锁文件内容格式: "<pid>@<hostname>\n"
+func writeLockHolder(f *os.File) error {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	if err = f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err = f.WriteAt([]byte(fmt.Sprintf("%d@%s\n", os.Getpid(), host)), 0); err != nil {
+		return err
+	}
+	return Fsync(f)
+}
+
+func readLockHolder(path string) (*LockHolder, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(f, 512))
+	if err != nil {
+		return nil, err
+	}
+	s := strings.TrimSpace(string(b))
+	i := strings.IndexByte(s, '@')
+	if i <= 0 {
+		return nil, fmt.Errorf("fileutil: malformed lock holder %q", s)
+	}
+	pid, err := strconv.Atoi(s[:i])
+	if err != nil {
+		return nil, err
+	}
+	return &LockHolder{PID: pid, Hostname: s[i+1:]}, nil
+}
@@ -0,0 +1,81 @@
+package fileutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockAndUnlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lock")
+
+	l, err := LockFile(path, os.O_WRONLY|os.O_CREATE, FileMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = TryLockFile(path, os.O_WRONLY, FileMode); !errors.Is(err, ErrLocked) {
+		t.Fatalf("err = %v, want %v", err, ErrLocked)
+	}
+
+	locked := make(chan struct{}, 1)
+	go func() {
+		bl, blerr := LockFile(path, os.O_WRONLY, FileMode)
+		if blerr != nil {
+			t.Error(blerr)
+			return
+		}
+		locked <- struct{}{}
+		if blerr = bl.Close(); blerr != nil {
+			t.Error(blerr)
+		}
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("locked twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-locked:
+	case <-time.After(1 * time.Second):
+		t.Fatal("waited too long to acquire the lock")
+	}
+}
+
+func TestTryLockFileHolderInfo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lock")
+
+	l, err := TryLockFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, FileMode, WithHolderInfo())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	_, err = TryLockFile(path, os.O_WRONLY|os.O_TRUNC, FileMode, WithHolderInfo())
+	var lerr *LockError
+	if !errors.As(err, &lerr) {
+		t.Fatalf("err = %v, want *LockError", err)
+	}
+	if lerr.Holder == nil || lerr.Holder.PID != os.Getpid() {
+		t.Fatalf("holder = %v, want pid %d", lerr.Holder, os.Getpid())
+	}
+}
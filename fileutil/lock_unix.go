@@ -0,0 +1,14 @@
+//go:build !windows && !plan9 && !linux
+// +build !windows,!plan9,!linux
+
+package fileutil
+
+import "os"
+
+func tryLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
+	return flockTryLockFile(path, flag, perm)
+}
+
+func lockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
+	return flockLockFile(path, flag, perm)
+}
@@ -0,0 +1,64 @@
+//go:build windows
+// +build windows
+
+package fileutil
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	modkernel32    = syscall.NewLazyDLL("kernel32.dll")
+	procLockFileEx = modkernel32.NewProc("LockFileEx")
+)
+
+const (
+	// 参见 https://docs.microsoft.com/en-us/windows/win32/api/fileapi/nf-fileapi-lockfileex
+	lockfileFailImmediately = 1
+	lockfileExclusiveLock   = 2
+
+	errLockViolation syscall.Errno = 0x21
+)
+
+func tryLockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
+	f, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockFileEx(syscall.Handle(f.Fd()), lockfileExclusiveLock|lockfileFailImmediately); err != nil {
+		f.Close()
+		if err == errLockViolation || err == syscall.ERROR_IO_PENDING {
+			err = ErrLocked
+		}
+		return nil, err
+	}
+	return &LockedFile{f}, nil
+}
+
+func lockFile(path string, flag int, perm os.FileMode) (*LockedFile, error) {
+	f, err := os.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockFileEx(syscall.Handle(f.Fd()), lockfileExclusiveLock); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &LockedFile{f}, nil
+}
+
+// lockFileEx从偏移0开始锁定最大范围，即整个文件
+func lockFileEx(h syscall.Handle, flags uint32) error {
+	var ol syscall.Overlapped
+	r1, _, e1 := syscall.Syscall6(procLockFileEx.Addr(), 6,
+		uintptr(h), uintptr(flags), 0, uintptr(^uint32(0)), uintptr(^uint32(0)), uintptr(unsafe.Pointer(&ol)))
+	if r1 == 0 {
+		if e1 != 0 {
+			return e1
+		}
+		return syscall.EINVAL
+	}
+	return nil
+}
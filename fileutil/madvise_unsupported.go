@@ -0,0 +1,6 @@
+// +build !linux
+
+package fileutil
+
+// 标准库syscall仅在linux上提供Madvise，其他平台忽略提示
+func madvise(b []byte, a Advice) error { return nil }
@@ -0,0 +1,438 @@
+package fileutil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MemFS是完全位于内存中的FS实现，用于单元测试
+// 路径经过filepath.Clean规范化，"/"与"."均视为已存在的根目录
+type MemFS struct {
+	mu    sync.Mutex
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	dir     bool
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte
+}
+
+// NewMemFS创建空的MemFS
+func NewMemFS() *MemFS {
+	now := time.Now()
+	return &MemFS{
+		nodes: map[string]*memNode{
+			"/": {dir: true, mode: DirMode, modTime: now},
+			".": {dir: true, mode: DirMode, modTime: now},
+		},
+	}
+}
+
+func (m *MemFS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := filepath.Clean(name)
+	writable := flag&(os.O_WRONLY|os.O_RDWR) != 0
+	n, ok := m.nodes[p]
+	switch {
+	case !ok:
+		if flag&os.O_CREATE == 0 {
+			return nil, &os.PathError{Op: "open", Path: name, Err: syscall.ENOENT}
+		}
+		if err := m.checkParent(p); err != nil {
+			return nil, &os.PathError{Op: "open", Path: name, Err: err}
+		}
+		n = &memNode{mode: perm & os.ModePerm, modTime: time.Now()}
+		m.nodes[p] = n
+	case flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL:
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EEXIST}
+	case n.dir && writable:
+		return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+	case flag&os.O_TRUNC != 0 && writable:
+		n.data = nil
+		n.modTime = time.Now()
+	}
+	return &memFile{fs: m, name: name, path: p, node: n, flag: flag}, nil
+}
+
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := filepath.Clean(name)
+	n, ok := m.nodes[p]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: syscall.ENOENT}
+	}
+	return n.info(p), nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := filepath.Clean(name)
+	n, ok := m.nodes[p]
+	if !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOENT}
+	}
+	if n.dir && len(m.children(p)) != 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	delete(m.nodes, p)
+	return nil
+}
+
+func (m *MemFS) RemoveAll(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := filepath.Clean(path)
+	for k := range m.nodes {
+		if k == p || isUnder(k, p) {
+			delete(m.nodes, k)
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	op, np := filepath.Clean(oldpath), filepath.Clean(newpath)
+	n, ok := m.nodes[op]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ENOENT}
+	}
+	if err := m.checkParent(np); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	if dst, ok := m.nodes[np]; ok {
+		switch {
+		case dst.dir && !n.dir:
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EISDIR}
+		case !dst.dir && n.dir:
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ENOTDIR}
+		case dst.dir && len(m.children(np)) != 0:
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ENOTEMPTY}
+		}
+	}
+	if op == np {
+		return nil
+	}
+
+	delete(m.nodes, op)
+	m.nodes[np] = n
+	if n.dir {
+		var moved []string
+		for k := range m.nodes {
+			if isUnder(k, op) {
+				moved = append(moved, k)
+			}
+		}
+		for _, k := range moved {
+			m.nodes[np+k[len(op):]] = m.nodes[k]
+			delete(m.nodes, k)
+		}
+	}
+	return nil
+}
+
+func (m *MemFS) MkdirAll(path string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	p := filepath.Clean(path)
+	var missing []string
+	for {
+		n, ok := m.nodes[p]
+		if ok {
+			if !n.dir {
+				return &os.PathError{Op: "mkdir", Path: p, Err: syscall.ENOTDIR}
+			}
+			break
+		}
+		missing = append(missing, p)
+		p = filepath.Dir(p)
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		m.nodes[missing[i]] = &memNode{dir: true, mode: perm & os.ModePerm, modTime: time.Now()}
+	}
+	return nil
+}
+
+// checkParent检查p的父目录是否存在 调用时需持有m.mu
+func (m *MemFS) checkParent(p string) error {
+	parent, ok := m.nodes[filepath.Dir(p)]
+	if !ok {
+		return syscall.ENOENT
+	}
+	if !parent.dir {
+		return syscall.ENOTDIR
+	}
+	return nil
+}
+
+// children返回目录p下直接子项的名称(已排序) 调用时需持有m.mu
+func (m *MemFS) children(p string) []string {
+	var names []string
+	for k := range m.nodes {
+		if k != p && filepath.Dir(k) == p {
+			names = append(names, filepath.Base(k))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isUnder(p, dir string) bool {
+	if dir == "/" {
+		return p != "/" && strings.HasPrefix(p, "/")
+	}
+	if dir == "." {
+		return p != "." && !filepath.IsAbs(p)
+	}
+	return strings.HasPrefix(p, dir+string(filepath.Separator))
+}
+
+func (n *memNode) info(p string) os.FileInfo {
+	mode := n.mode
+	if n.dir {
+		mode |= os.ModeDir
+	}
+	return &memFileInfo{name: filepath.Base(p), size: int64(len(n.data)), mode: mode, modTime: n.modTime}
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return fi.size }
+func (fi *memFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *memFileInfo) Sys() interface{}   { return nil }
+
+// memFile是MemFS中打开的文件句柄
+// 文件被删除或rename后，句柄依然指向原有的node，与unix语义一致
+type memFile struct {
+	fs     *MemFS
+	name   string
+	path   string
+	node   *memNode
+	flag   int
+	off    int64
+	dirOff int
+	closed bool
+}
+
+func (f *memFile) Name() string { return f.name }
+
+func (f *memFile) check(op string, write bool) error {
+	if f.closed {
+		return &os.PathError{Op: op, Path: f.name, Err: os.ErrClosed}
+	}
+	if write && f.flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return &os.PathError{Op: op, Path: f.name, Err: syscall.EBADF}
+	}
+	if !write && f.flag&os.O_WRONLY != 0 {
+		return &os.PathError{Op: op, Path: f.name, Err: syscall.EBADF}
+	}
+	return nil
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	n, err := f.readAt("read", p, f.off)
+	f.off += int64(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	n, err := f.readAt("read", p, off)
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *memFile) readAt(op string, p []byte, off int64) (int, error) {
+	if err := f.check(op, false); err != nil {
+		return 0, err
+	}
+	if f.node.dir {
+		return 0, &os.PathError{Op: op, Path: f.name, Err: syscall.EISDIR}
+	}
+	if off >= int64(len(f.node.data)) {
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	return copy(p, f.node.data[off:]), nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	off := f.off
+	if f.flag&os.O_APPEND != 0 {
+		off = int64(len(f.node.data))
+	}
+	n, err := f.writeAt("write", p, off)
+	f.off = off + int64(n)
+	return n, err
+}
+
+func (f *memFile) WriteAt(p []byte, off int64) (int, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	return f.writeAt("write", p, off)
+}
+
+func (f *memFile) writeAt(op string, p []byte, off int64) (int, error) {
+	if err := f.check(op, true); err != nil {
+		return 0, err
+	}
+	if end := off + int64(len(p)); end > int64(len(f.node.data)) {
+		f.node.data = growBytes(f.node.data, end)
+	}
+	copy(f.node.data[off:], p)
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: os.ErrClosed}
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return nil, &os.PathError{Op: "stat", Path: f.name, Err: os.ErrClosed}
+	}
+	return f.node.info(f.path), nil
+}
+
+func (f *memFile) Sync() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return &os.PathError{Op: "sync", Path: f.name, Err: os.ErrClosed}
+	}
+	return nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if err := f.check("truncate", true); err != nil {
+		return err
+	}
+	if size < 0 {
+		return &os.PathError{Op: "truncate", Path: f.name, Err: syscall.EINVAL}
+	}
+	if size <= int64(len(f.node.data)) {
+		f.node.data = f.node.data[:size]
+	} else {
+		f.node.data = growBytes(f.node.data, size)
+	}
+	f.node.modTime = time.Now()
+	return nil
+}
+
+func (f *memFile) Readdirnames(n int) ([]string, error) {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return nil, &os.PathError{Op: "readdirent", Path: f.name, Err: os.ErrClosed}
+	}
+	if !f.node.dir {
+		return nil, &os.PathError{Op: "readdirent", Path: f.name, Err: syscall.ENOTDIR}
+	}
+	names := f.fs.children(f.path)
+	if f.dirOff > len(names) {
+		f.dirOff = len(names)
+	}
+	names = names[f.dirOff:]
+	if n <= 0 {
+		f.dirOff += len(names)
+		return names, nil
+	}
+	if len(names) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(names) {
+		n = len(names)
+	}
+	f.dirOff += n
+	return names[:n], nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.name, Err: os.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+// growBytes将b扩展到size长度，新增部分填0
+func growBytes(b []byte, size int64) []byte {
+	if int64(cap(b)) >= size {
+		n := len(b)
+		b = b[:size]
+		for i := n; i < len(b); i++ {
+			b[i] = 0
+		}
+		return b
+	}
+	nb := make([]byte, size, size*2)
+	copy(nb, b)
+	return nb
+}
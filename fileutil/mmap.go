@@ -0,0 +1,153 @@
+package fileutil
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Advice是传给madvise的访问模式提示
+type Advice int
+
+const (
+	AdviceNormal Advice = iota
+	AdviceSequential
+	AdviceRandom
+	AdviceWillNeed
+	AdviceDontNeed
+)
+
+var (
+	// ErrMmapClosed表示Mmap已经被Close
+	ErrMmapClosed = errors.New("fileutil: mmap already closed")
+	// ErrMmapBorrowed表示仍有Borrow得到的切片未Release，此时不能解除映射
+	ErrMmapBorrowed = errors.New("fileutil: mmap has outstanding borrows")
+)
+
+// Mmap是文件的只读内存映射，适用于只读的大文件(例如snapshot、索引文件)
+// ReadAt/Advise与Close互斥，Close之后调用返回ErrMmapClosed
+// 直接访问映射的内存需要通过Borrow/Release，存在未Release的切片时Close返回ErrMmapBorrowed
+type Mmap struct {
+	mu      sync.RWMutex
+	data    []byte
+	borrows int
+	closed  bool
+}
+
+// OpenMmap以只读方式映射path的全部内容
+func OpenMmap(path string) (*Mmap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// 映射建立之后关闭文件不影响映射
+	defer f.Close()
+	return MmapFile(f)
+}
+
+// MmapFile以只读方式映射f的全部内容，f可以在返回后关闭
+func MmapFile(f *os.File) (*Mmap, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size == 0 {
+		// 长度为0的mmap会返回EINVAL
+		return &Mmap{}, nil
+	}
+	if int64(int(size)) != size {
+		return nil, errors.New("fileutil: file too large to mmap")
+	}
+	data, err := mmap(f, int(size))
+	if err != nil {
+		return nil, &os.PathError{Op: "mmap", Path: f.Name(), Err: err}
+	}
+	return &Mmap{data: data}, nil
+}
+
+// Borrow返回映射的内存，在对应的Release之前映射不会被解除
+// 切片不能被修改，并且在Release之后不能再访问
+func (m *Mmap) Borrow() ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrMmapClosed
+	}
+	m.borrows++
+	return m.data, nil
+}
+
+// Release归还Borrow得到的切片
+func (m *Mmap) Release() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.borrows == 0 {
+		panic("fileutil: Mmap.Release without Borrow")
+	}
+	m.borrows--
+}
+
+// Len返回映射的长度
+func (m *Mmap) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.data)
+}
+
+// ReadAt实现io.ReaderAt
+func (m *Mmap) ReadAt(p []byte, off int64) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return 0, ErrMmapClosed
+	}
+	if off < 0 {
+		return 0, os.ErrInvalid
+	}
+	if off >= int64(len(m.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Advise提示内核后续的访问模式
+func (m *Mmap) Advise(a Advice) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.closed {
+		return ErrMmapClosed
+	}
+	if len(m.data) == 0 {
+		return nil
+	}
+	return madvise(m.data, a)
+}
+
+// Close解除映射 等待正在进行的ReadAt完成
+// 存在未Release的切片时不解除映射，返回ErrMmapBorrowed，可以在全部Release之后重试
+func (m *Mmap) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrMmapClosed
+	}
+	if m.borrows > 0 {
+		return ErrMmapBorrowed
+	}
+	m.closed = true
+	data := m.data
+	m.data = nil
+	if len(data) == 0 {
+		return nil
+	}
+	return munmap(data)
+}
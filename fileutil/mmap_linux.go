@@ -0,0 +1,21 @@
+// +build linux
+
+package fileutil
+
+import "syscall"
+
+var madviseFlags = map[Advice]int{
+	AdviceNormal:     syscall.MADV_NORMAL,
+	AdviceSequential: syscall.MADV_SEQUENTIAL,
+	AdviceRandom:     syscall.MADV_RANDOM,
+	AdviceWillNeed:   syscall.MADV_WILLNEED,
+	AdviceDontNeed:   syscall.MADV_DONTNEED,
+}
+
+func madvise(b []byte, a Advice) error {
+	flag, ok := madviseFlags[a]
+	if !ok {
+		return syscall.EINVAL
+	}
+	return syscall.Madvise(b, flag)
+}
@@ -0,0 +1,64 @@
+package fileutil
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestMmap(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "data")
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	if err := ioutil.WriteFile(p, data, FileMode); err != nil {
+		t.Fatal(err)
+	}
+	m, err := OpenMmap(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != len(data) {
+		t.Errorf("len = %d, want %d", m.Len(), len(data))
+	}
+	if err = m.Advise(AdviceSequential); err != nil {
+		t.Error(err)
+	}
+
+	buf := make([]byte, 16)
+	if n, err := m.ReadAt(buf, int64(len(data)-10)); n != 10 || err != io.EOF || !bytes.Equal(buf[:n], data[len(data)-10:]) {
+		t.Errorf("ReadAt = %d, %v", n, err)
+	}
+
+	b, err := m.Borrow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Error("borrowed bytes mismatch")
+	}
+	// 存在未归还的切片时不能解除映射
+	if err = m.Close(); err != ErrMmapBorrowed {
+		t.Fatalf("err = %v, want %v", err, ErrMmapBorrowed)
+	}
+	if b[0] != '0' {
+		t.Error("mapping changed after refused Close")
+	}
+	m.Release()
+	if err = m.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = m.Borrow(); err != ErrMmapClosed {
+		t.Errorf("Borrow err = %v, want %v", err, ErrMmapClosed)
+	}
+	if _, err = m.ReadAt(buf, 0); err != ErrMmapClosed {
+		t.Errorf("ReadAt err = %v, want %v", err, ErrMmapClosed)
+	}
+	if err = m.Advise(AdviceRandom); err != ErrMmapClosed {
+		t.Errorf("Advise err = %v, want %v", err, ErrMmapClosed)
+	}
+	if err = m.Close(); err != ErrMmapClosed {
+		t.Errorf("Close err = %v, want %v", err, ErrMmapClosed)
+	}
+}
@@ -0,0 +1,19 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+)
+
+var errMmapUnsupported = errors.New("fileutil: mmap is not supported on this platform")
+
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, errMmapUnsupported
+}
+
+func munmap(b []byte) error {
+	return errMmapUnsupported
+}
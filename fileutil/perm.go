@@ -0,0 +1,122 @@
+package fileutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PermissionViolation描述一个权限宽于FileMode/DirMode或者属主不是当前用户的文件
+type PermissionViolation struct {
+	Path       string
+	Mode       os.FileMode // 实际权限
+	Want       os.FileMode // 允许的最宽权限
+	UID        int
+	WrongOwner bool
+}
+
+func (v PermissionViolation) String() string {
+	var problems []string
+	if v.Mode&^v.Want != 0 {
+		problems = append(problems, fmt.Sprintf("mode %#o, want at most %#o", v.Mode, v.Want))
+	}
+	if v.WrongOwner {
+		problems = append(problems, fmt.Sprintf("owned by uid %d, want %d", v.UID, os.Geteuid()))
+	}
+	return v.Path + ": " + strings.Join(problems, ", ")
+}
+
+// PermissionError汇总检查发现的所有问题
+type PermissionError struct {
+	Violations []PermissionViolation
+}
+
+func (e *PermissionError) Error() string {
+	s := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		s[i] = v.String()
+	}
+	return "fileutil: insecure permissions: " + strings.Join(s, "; ")
+}
+
+// CheckFilePermission检查path的权限不宽于FileMode(目录为DirMode)并且属主为当前用户
+func CheckFilePermission(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if v, ok := checkPermission(path, fi); !ok {
+		return &PermissionError{Violations: []PermissionViolation{v}}
+	}
+	return nil
+}
+
+// CheckDirPermission递归检查dir，目录的权限不能宽于DirMode，文件不能宽于FileMode，属主必须为当前用户
+// 存在问题时返回*PermissionError，符号链接被忽略
+func CheckDirPermission(dir string) error {
+	vs, err := walkPermissions(dir)
+	if err != nil {
+		return err
+	}
+	if len(vs) != 0 {
+		return &PermissionError{Violations: vs}
+	}
+	return nil
+}
+
+// EnforcePermissions递归收紧dir下过宽的权限
+// 属主无法修改，仍然存在问题的文件通过*PermissionError返回
+func EnforcePermissions(dir string) error {
+	vs, err := walkPermissions(dir)
+	if err != nil {
+		return err
+	}
+
+	var remaining []PermissionViolation
+	for _, v := range vs {
+		if v.Mode&^v.Want != 0 {
+			if err = os.Chmod(v.Path, v.Mode&v.Want); err != nil {
+				return err
+			}
+			plog.Noticef("tightened permission of %s from %#o to %#o", v.Path, v.Mode, v.Mode&v.Want)
+			v.Mode &= v.Want
+		}
+		if v.WrongOwner {
+			remaining = append(remaining, v)
+		}
+	}
+	if len(remaining) != 0 {
+		return &PermissionError{Violations: remaining}
+	}
+	return nil
+}
+
+func walkPermissions(dir string) ([]PermissionViolation, error) {
+	var vs []PermissionViolation
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if v, ok := checkPermission(path, info); !ok {
+			vs = append(vs, v)
+		}
+		return nil
+	})
+	return vs, err
+}
+
+func checkPermission(path string, fi os.FileInfo) (PermissionViolation, bool) {
+	if fi.Mode()&os.ModeSymlink != 0 {
+		return PermissionViolation{}, true
+	}
+	v := PermissionViolation{Path: path, Mode: fi.Mode().Perm(), Want: FileMode, UID: -1}
+	if fi.IsDir() {
+		v.Want = DirMode
+	}
+	if uid, ok := fileOwner(fi); ok {
+		v.UID = uid
+		v.WrongOwner = v.UID != os.Geteuid()
+	}
+	return v, v.Mode&^v.Want == 0 && !v.WrongOwner
+}
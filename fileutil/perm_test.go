@@ -0,0 +1,64 @@
+package fileutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnforcePermissions(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, DirMode); err != nil {
+		t.Fatal(err)
+	}
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	loose, tight := filepath.Join(sub, "loose"), filepath.Join(dir, "tight")
+	for p, mode := range map[string]os.FileMode{loose: 0644, tight: FileMode} {
+		if err := ioutil.WriteFile(p, nil, mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(p, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chmod(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckFilePermission(tight); err != nil {
+		t.Errorf("CheckFilePermission(tight) = %v", err)
+	}
+	err := CheckDirPermission(dir)
+	var perr *PermissionError
+	if !errors.As(err, &perr) {
+		t.Fatalf("err = %v, want *PermissionError", err)
+	}
+	got := map[string]os.FileMode{}
+	for _, v := range perr.Violations {
+		got[v.Path] = v.Mode
+	}
+	if len(got) != 2 || got[sub] != 0755 || got[loose] != 0644 {
+		t.Errorf("violations = %+v", perr.Violations)
+	}
+
+	if err = EnforcePermissions(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err = CheckDirPermission(dir); err != nil {
+		t.Errorf("after enforce: %v", err)
+	}
+	for p, want := range map[string]os.FileMode{sub: 0700 & DirMode, loose: 0600 & FileMode} {
+		fi, err := os.Stat(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Mode().Perm() != want {
+			t.Errorf("%s: mode = %v, want %v", p, fi.Mode().Perm(), want)
+		}
+	}
+}
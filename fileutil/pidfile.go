@@ -0,0 +1,168 @@
+package fileutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrProcessRunning表示PID文件记录的进程依然存活
+	ErrProcessRunning = errors.New("fileutil: process recorded in pid file is still running")
+)
+
+// 清理过期PID文件之后重试的次数
+const pidFileRetries = 3
+
+// PIDFile是当前进程创建的PID文件
+type PIDFile struct {
+	path string
+	pid  int
+}
+
+// CreatePIDFile在path写入当前进程的PID
+// 若是path已存在且记录的进程依然存活(并且/proc/<pid>/cmdline与当前程序一致)，则返回ErrProcessRunning；
+// 否则视为崩溃遗留的过期文件，删除后重新创建
+// 内容先写入临时文件并fsync，再通过link原子地创建path，不会出现内容为空的PID文件
+// 检查、删除过期文件与创建的整个过程持有<path>.lock的锁，同时启动的多个进程中只有一个能够成功
+func CreatePIDFile(path string) (*PIDFile, error) {
+	l, err := lockPIDFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+
+	pid := os.Getpid()
+	for i := 0; i < pidFileRetries; i++ {
+		err = linkPIDFile(path, pid)
+		if err == nil {
+			return &PIDFile{path: path, pid: pid}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		old, rerr := readPIDFile(path)
+		if rerr != nil && !os.IsNotExist(rerr) {
+			// 内容无法解析，视为过期文件
+			plog.Warningf("removing malformed pid file %s (%v)", path, rerr)
+		} else if rerr == nil && old != pid && pidRunning(old) {
+			return nil, fmt.Errorf("%w: pid %d (%s)", ErrProcessRunning, old, path)
+		} else if rerr == nil {
+			plog.Infof("removing stale pid file %s (pid %d)", path, old)
+		}
+		if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("fileutil: failed to create pid file %s after %d attempts", path, pidFileRetries)
+}
+
+// Path返回PID文件路径
+func (p *PIDFile) Path() string { return p.path }
+
+// Remove删除PID文件 文件已被其他进程替换时不做处理
+func (p *PIDFile) Remove() error {
+	l, err := lockPIDFile(p.path)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	pid, err := readPIDFile(p.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if pid != p.pid {
+		return nil
+	}
+	if err = os.Remove(p.path); err != nil {
+		return err
+	}
+	return FsyncDir(filepath.Dir(p.path))
+}
+
+// lockPIDFile对PID文件旁的<path>.lock加锁
+// 锁文件不会被删除，否则持有旧文件锁的进程与新建文件的进程将不再互斥
+func lockPIDFile(path string) (*LockedFile, error) {
+	return LockFile(path+".lock", os.O_WRONLY|os.O_CREATE, 0644)
+}
+
+func linkPIDFile(path string, pid int) error {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	tmp, err := ioutil.TempFile(dir, "."+base+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(strconv.Itoa(pid) + "\n")
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if err == nil {
+		err = Fsync(tmp)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Link(tmp.Name(), path); err != nil {
+		return err
+	}
+	return FsyncDir(dir)
+}
+
+func readPIDFile(path string) (int, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// pidRunning判断pid对应的进程是否存活并且与当前进程运行的是同一个程序
+// PID被其他程序复用时视为不存活；没有/proc时只检查进程是否存在
+func pidRunning(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	if !processExists(pid) {
+		return false
+	}
+
+	cmdline, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		// 进程在检查期间退出
+		if _, serr := os.Stat("/proc/self"); serr == nil {
+			return false
+		}
+		return true
+	}
+	self, err := ioutil.ReadFile("/proc/self/cmdline")
+	if err != nil {
+		return true
+	}
+	return filepath.Base(argv0(cmdline)) == filepath.Base(argv0(self))
+}
+
+func argv0(cmdline []byte) string {
+	if i := bytes.IndexByte(cmdline, 0); i >= 0 {
+		cmdline = cmdline[:i]
+	}
+	return string(cmdline)
+}
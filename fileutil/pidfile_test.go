@@ -0,0 +1,116 @@
+package fileutil
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// 由子进程执行: 创建PIDFILE_HELPER指定的PID文件，成功后保持运行一段时间
+func TestPIDFileHelperProcess(t *testing.T) {
+	path := os.Getenv("PIDFILE_HELPER")
+	if path == "" {
+		return
+	}
+	if _, err := CreatePIDFile(path); err != nil {
+		os.Exit(3)
+	}
+	time.Sleep(time.Second)
+	os.Exit(0)
+}
+
+func pidFileHelper(path string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPIDFileHelperProcess$")
+	cmd.Env = append(os.Environ(), "PIDFILE_HELPER="+path)
+	return cmd
+}
+
+func TestCreatePIDFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	p, err := CreatePIDFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pid, err := readPIDFile(path); err != nil || pid != os.Getpid() {
+		t.Errorf("pid = %d, %v, want %d", pid, err, os.Getpid())
+	}
+	if err = p.Remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("pid file not removed: %v", err)
+	}
+}
+
+func TestCreatePIDFileStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	for _, content := range []string{"999999999\n", "garbage"} {
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		p, err := CreatePIDFile(path)
+		if err != nil {
+			t.Fatalf("%q: %v", content, err)
+		}
+		p.Remove()
+	}
+}
+
+func TestCreatePIDFileRunning(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	cmd := pidFileHelper(path)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if pid, err := readPIDFile(path); err == nil && pid == cmd.Process.Pid {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("helper did not create pid file")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := CreatePIDFile(path); !errors.Is(err, ErrProcessRunning) {
+		t.Errorf("err = %v, want %v", err, ErrProcessRunning)
+	}
+}
+
+func TestCreatePIDFileConcurrentStale(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.pid")
+	if err := ioutil.WriteFile(path, []byte("999999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmds := make([]*exec.Cmd, 4)
+	for i := range cmds {
+		cmds[i] = pidFileHelper(path)
+		if err := cmds[i].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	won := 0
+	for _, cmd := range cmds {
+		if err := cmd.Wait(); err == nil {
+			won++
+		}
+	}
+	if won != 1 {
+		t.Errorf("%d processes created the pid file, want 1", won)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil {
+		t.Error(err)
+	} else if _, err = strconv.Atoi(string(b[:len(b)-1])); err != nil {
+		t.Errorf("pid file content %q", b)
+	}
+}
@@ -0,0 +1,11 @@
+// +build !windows,!plan9
+
+package fileutil
+
+import "syscall"
+
+// processExists通过signal 0判断进程是否存在 EPERM表示进程存在但属于其他用户
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,14 @@
+// +build windows plan9
+
+package fileutil
+
+import "os"
+
+func processExists(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
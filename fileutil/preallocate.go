@@ -19,6 +19,25 @@ func Preallocate(f *os.File, sizeInBytes int64, extendFile bool) error {
 	return preallocFixed(f, sizeInBytes)
 }
 
+// PreallocateFile与Preallocate相同，f可以是任意FS打开的文件
+// 非*os.File仅在extendFile时通过Truncate扩展文件大小
+func PreallocateFile(f File, sizeInBytes int64, extendFile bool) error {
+	if osf, ok := f.(*os.File); ok {
+		return Preallocate(osf, sizeInBytes, extendFile)
+	}
+	if sizeInBytes == 0 || !extendFile {
+		return nil
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() >= sizeInBytes {
+		return nil
+	}
+	return f.Truncate(sizeInBytes)
+}
+
 //
 func preallocExtendTrunc(f *os.File, sizeInBytes int64) error {
 	curOff, err := f.Seek(0, io.SeekCurrent)
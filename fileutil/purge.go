@@ -0,0 +1,88 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// PurgeFile在后台每隔interval扫描一次dirname，仅保留最新的max个以suffix结尾的文件
+// 文件名按字典序排序，序号越大越新；遇到被其他句柄加锁的文件时停止本轮清理，
+// 不会越过它删除更新的文件，避免序号出现空洞
+// 出错时错误写入返回的channel，随后后台goroutine退出；关闭stop则停止清理
+func PurgeFile(dirname string, suffix string, max uint, interval time.Duration, stop <-chan struct{}) <-chan error {
+	return purgeFile(dirname, suffix, max, interval, stop, nil)
+}
+
+// purgec用于测试时获取被删除的文件
+func purgeFile(dirname string, suffix string, max uint, interval time.Duration, stop <-chan struct{}, purgec chan<- string) <-chan error {
+	errC := make(chan error, 1)
+	go func() {
+		for {
+			purged, err := purgeOnce(dirname, func(name string) bool {
+				return strings.HasSuffix(name, suffix)
+			}, max)
+			if purgec != nil {
+				for _, f := range purged {
+					purgec <- f
+				}
+			}
+			if err != nil {
+				errC <- err
+				return
+			}
+			select {
+			case <-time.After(interval):
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return errC
+}
+
+// purgeOnce删除dirname下满足match的多余的旧文件，返回被删除文件的路径
+func purgeOnce(dirname string, match func(name string) bool, max uint) ([]string, error) {
+	fnames, err := ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+	// ReadDir的结果已排序 过滤后依然有序
+	matched := make([]string, 0, len(fnames))
+	for _, fname := range fnames {
+		if match(fname) {
+			matched = append(matched, fname)
+		}
+	}
+
+	var purged []string
+	remain := len(matched)
+	for _, fname := range matched {
+		if remain <= int(max) {
+			break
+		}
+		f := filepath.Join(dirname, fname)
+		l, err := TryLockFile(f, os.O_WRONLY, FileMode)
+		if err != nil {
+			if errors.Is(err, ErrLocked) {
+				plog.Infof("stopped purging at locked file %s", f)
+				break
+			}
+			return purged, err
+		}
+		if err = os.Remove(f); err != nil {
+			l.Close()
+			return purged, err
+		}
+		if err = l.Close(); err != nil {
+			plog.Errorf("error unlocking %s when purging file (%v)", l.Name(), err)
+			return purged, err
+		}
+		plog.Infof("purged file %s successfully", f)
+		purged = append(purged, f)
+		remain--
+	}
+	return purged, nil
+}
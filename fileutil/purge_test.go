@@ -0,0 +1,69 @@
+package fileutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPurgeFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "purgefile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for i := 0; i < 5; i++ {
+		f, ferr := os.Create(filepath.Join(dir, fmt.Sprintf("%d.test", i)))
+		if ferr != nil {
+			t.Fatal(ferr)
+		}
+		f.Close()
+	}
+	// 被加锁的文件及其之后的文件不会被删除
+	l, err := TryLockFile(filepath.Join(dir, "1.test"), os.O_WRONLY, FileMode)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop, purgec := make(chan struct{}), make(chan string, 10)
+	errch := purgeFile(dir, "test", 3, time.Millisecond, stop, purgec)
+	for i, want := range []string{"0.test", "1.test"} {
+		select {
+		case f := <-purgec:
+			if f != filepath.Join(dir, want) {
+				t.Errorf("purged = %s, want %s", f, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("purge took too long")
+		}
+		if i == 0 {
+			// 解锁前1.test之后的文件都不会被删除
+			select {
+			case f := <-purgec:
+				t.Fatalf("unexpected purge of %s while 1.test is locked", f)
+			case <-time.After(10 * time.Millisecond):
+			}
+			l.Close()
+		}
+	}
+	close(stop)
+
+	select {
+	case err = <-errch:
+		t.Errorf("unexpected purge error %v", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	fnames, err := ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"2.test", "3.test", "4.test"}; !reflect.DeepEqual(fnames, want) {
+		t.Errorf("fnames = %v, want %v", fnames, want)
+	}
+}
@@ -0,0 +1,244 @@
+package fileutil
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 备份文件名中的时间戳格式，字典序即时间顺序
+	backupTimeFormat = "20060102T150405.000000000"
+	gzipSuffix       = ".gz"
+)
+
+// RotateOptions配置RotatingFile 零值字段表示不启用对应的功能
+type RotateOptions struct {
+	// 当前文件超过MaxBytes时轮转
+	MaxBytes int64
+	// 跨过Interval整数倍的时间边界时轮转，例如time.Hour表示每个整点
+	Interval time.Duration
+	// 最多保留的备份数量，0表示全部保留
+	MaxBackups uint
+	// 在后台将备份压缩为gzip
+	Compress bool
+}
+
+// RotatingFile是按大小或时间轮转的io.WriteCloser
+// 轮转时当前文件被fsync后重命名为"<name>.<UTC时间戳>"，并重新创建name
+// 备份的压缩与清理在后台goroutine中按轮转顺序依次执行，清理复用PurgeFile的逻辑
+type RotatingFile struct {
+	path string
+	opts RotateOptions
+	now  func() time.Time
+
+	mu         sync.Mutex
+	f          *os.File
+	size       int64
+	nextRotate time.Time
+	closed     bool
+
+	backupc chan string
+	donec   chan struct{}
+}
+
+// NewRotatingFile以追加方式打开path，所在目录不存在时通过TouchDirAll创建
+func NewRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	if err := TouchDirAll(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+	r := &RotatingFile{
+		path:    path,
+		opts:    opts,
+		now:     time.Now,
+		backupc: make(chan string, 16),
+		donec:   make(chan struct{}),
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	go r.processBackups()
+	return r, nil
+}
+
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.shouldRotate(len(p)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// Rotate立即轮转，例如收到SIGHUP时
+func (r *RotatingFile) Rotate() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return os.ErrClosed
+	}
+	return r.rotate()
+}
+
+// Close fsync并关闭当前文件，等待后台的压缩与清理完成
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return os.ErrClosed
+	}
+	r.closed = true
+	err := Fsync(r.f)
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	close(r.backupc)
+	r.mu.Unlock()
+
+	<-r.donec
+	return err
+}
+
+func (r *RotatingFile) shouldRotate(n int) bool {
+	if r.opts.MaxBytes > 0 && r.size > 0 && r.size+int64(n) > r.opts.MaxBytes {
+		return true
+	}
+	if r.opts.Interval <= 0 {
+		return false
+	}
+	now := r.now()
+	if now.Before(r.nextRotate) {
+		return false
+	}
+	if r.size == 0 {
+		// 空文件不产生备份，直接进入新的时间段
+		r.nextRotate = now.Truncate(r.opts.Interval).Add(r.opts.Interval)
+		return false
+	}
+	return true
+}
+
+func (r *RotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, FileMode)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	if r.opts.Interval > 0 {
+		r.nextRotate = r.now().Truncate(r.opts.Interval).Add(r.opts.Interval)
+	}
+	return nil
+}
+
+// rotate先重命名再关闭当前文件，任何一步失败时当前文件依然可以继续写入
+func (r *RotatingFile) rotate() error {
+	if err := Fsync(r.f); err != nil {
+		return err
+	}
+
+	backup := r.backupName()
+	if err := os.Rename(r.path, backup); err != nil {
+		return err
+	}
+	old := r.f
+	if err := r.open(); err != nil {
+		if rerr := os.Rename(backup, r.path); rerr != nil {
+			plog.Errorf("failed to restore %s after rotation failure (%v)", r.path, rerr)
+		}
+		return err
+	}
+	if err := old.Close(); err != nil {
+		plog.Warningf("failed to close rotated file %s (%v)", backup, err)
+	}
+	if err := FsyncDir(filepath.Dir(r.path)); err != nil {
+		return err
+	}
+	r.backupc <- backup
+	return nil
+}
+
+func (r *RotatingFile) backupName() string {
+	t := r.now().UTC()
+	for {
+		name := r.path + "." + t.Format(backupTimeFormat)
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			if _, err = os.Lstat(name + gzipSuffix); os.IsNotExist(err) {
+				return name
+			}
+		}
+		t = t.Add(time.Nanosecond)
+	}
+}
+
+// isBackup判断name是否为当前文件的备份
+func (r *RotatingFile) isBackup(name string) bool {
+	prefix := filepath.Base(r.path) + "."
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	ts := strings.TrimSuffix(name[len(prefix):], gzipSuffix)
+	_, err := time.Parse(backupTimeFormat, ts)
+	return err == nil
+}
+
+func (r *RotatingFile) processBackups() {
+	defer close(r.donec)
+	for backup := range r.backupc {
+		if r.opts.Compress {
+			// 轮转快于压缩时，排队中的备份可能已经被之前的清理删除
+			if err := gzipFile(backup); err != nil && !os.IsNotExist(err) {
+				plog.Errorf("failed to compress %s (%v)", backup, err)
+			}
+		}
+		if r.opts.MaxBackups > 0 {
+			if _, err := purgeOnce(filepath.Dir(r.path), r.isBackup, r.opts.MaxBackups); err != nil {
+				plog.Errorf("failed to purge backups of %s (%v)", r.path, err)
+			}
+		}
+	}
+}
+
+// gzipFile将path压缩为path.gz并删除path
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	w, err := NewAtomicWriter(path+gzipSuffix, FileMode)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	_, err = io.Copy(zw, in)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		w.Abort()
+		return err
+	}
+	if err = w.Commit(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
@@ -0,0 +1,194 @@
+package fileutil
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func listBackups(t *testing.T, r *RotatingFile) []string {
+	names, err := ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var backups []string
+	for _, name := range names {
+		if r.isBackup(name) {
+			backups = append(backups, filepath.Join(filepath.Dir(r.path), name))
+		}
+	}
+	sort.Strings(backups)
+	return backups
+}
+
+func readBackup(t *testing.T, p string) string {
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if !strings.HasSuffix(p, gzipSuffix) {
+		b, err := ioutil.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "logs", "app.log")
+	r, err := NewRotatingFile(p, RotateOptions{MaxBytes: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err = r.Write([]byte("12345")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups := listBackups(t, r)
+	if len(backups) != 1 || readBackup(t, backups[0]) != "1234512345" {
+		t.Fatalf("backups = %v", backups)
+	}
+	if b, _ := ioutil.ReadFile(p); string(b) != "12345" {
+		t.Errorf("current = %q, want %q", b, "12345")
+	}
+}
+
+func TestRotatingFileInterval(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "app.log")
+	r, err := NewRotatingFile(p, RotateOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC)
+	r.mu.Lock()
+	r.now = func() time.Time { return now }
+	r.nextRotate = now.Truncate(time.Hour).Add(time.Hour)
+	r.mu.Unlock()
+
+	r.Write([]byte("a"))
+	now = now.Add(20 * time.Minute)
+	r.Write([]byte("b"))
+	// 跨过11:00
+	now = now.Add(20 * time.Minute)
+	r.Write([]byte("c"))
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups := listBackups(t, r)
+	if len(backups) != 1 || readBackup(t, backups[0]) != "ab" {
+		t.Fatalf("backups = %v", backups)
+	}
+	if want := p + "." + time.Date(2020, 1, 1, 11, 10, 0, 0, time.UTC).Format(backupTimeFormat); backups[0] != want {
+		t.Errorf("backup = %s, want %s", backups[0], want)
+	}
+	if b, _ := ioutil.ReadFile(p); string(b) != "c" {
+		t.Errorf("current = %q, want %q", b, "c")
+	}
+}
+
+func TestRotatingFileIntervalSkipsEmpty(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "app.log")
+	r, err := NewRotatingFile(p, RotateOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 1, 10, 30, 0, 0, time.UTC)
+	r.mu.Lock()
+	r.now = func() time.Time { return now }
+	r.nextRotate = now.Truncate(time.Hour).Add(time.Hour)
+	r.mu.Unlock()
+
+	// 11:00之前没有写入，之后的写入都属于11:00开始的时间段
+	now = now.Add(40 * time.Minute)
+	r.Write([]byte("a"))
+	now = now.Add(20 * time.Minute)
+	r.Write([]byte("b"))
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if backups := listBackups(t, r); len(backups) != 0 {
+		t.Fatalf("backups = %v, want none", backups)
+	}
+	if b, _ := ioutil.ReadFile(p); string(b) != "ab" {
+		t.Errorf("current = %q, want %q", b, "ab")
+	}
+}
+
+func TestRotatingFileRotateFailure(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "app.log")
+	r, err := NewRotatingFile(p, RotateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 当前文件被外部删除时重命名失败，已打开的文件依然可以写入
+	if err = os.Remove(p); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Rotate(); err == nil {
+		t.Fatal("expected rotation failure")
+	}
+	if _, err = r.Write([]byte("a")); err != nil {
+		t.Errorf("write after failed rotation: %v", err)
+	}
+	if err = r.Close(); err != nil {
+		t.Errorf("close after failed rotation: %v", err)
+	}
+}
+
+func TestRotatingFileMaxBackupsCompress(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "app.log")
+	r, err := NewRotatingFile(p, RotateOptions{MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"one", "two", "three", "four"} {
+		if _, err = r.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+		if err = r.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = r.Rotate(); err != os.ErrClosed {
+		t.Errorf("Rotate after Close: err = %v, want %v", err, os.ErrClosed)
+	}
+
+	backups := listBackups(t, r)
+	if len(backups) != 2 {
+		t.Fatalf("backups = %v, want 2", backups)
+	}
+	for i, want := range []string{"three", "four"} {
+		if !strings.HasSuffix(backups[i], gzipSuffix) {
+			t.Errorf("backup %s not compressed", backups[i])
+		}
+		if got := readBackup(t, backups[i]); got != want {
+			t.Errorf("backup #%d = %q, want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package fileutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+)
+
+// ManifestEntry描述目录中的一个普通文件
+// Path为相对于目录根的路径，统一使用'/'分隔
+type ManifestEntry struct {
+	Path   string
+	Size   int64
+	Mode   os.FileMode
+	SHA256 string
+}
+
+// ManifestDiff是VerifyManifest的校验结果
+type ManifestDiff struct {
+	Missing  []string // manifest中存在但目录中不存在
+	Extra    []string // 目录中存在但manifest中不存在
+	Modified []string // 大小、权限或者内容不一致
+}
+
+// Clean表示目录与manifest完全一致
+func (d *ManifestDiff) Clean() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Modified) == 0
+}
+
+// SnapshotDir将src目录树快照到dst，dst必须不存在
+// 普通文件使用硬链接，src与dst跨文件系统时退化为拷贝；符号链接被重建，其他特殊文件被忽略
+// 硬链接与源文件共享inode，仅适用于写入完成后不再原地修改的文件(例如snapshot、已写满的WAL segment)
+func SnapshotDir(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("fileutil: snapshot destination %q already exists", dst)
+	}
+
+	var dirs []string
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch mode := info.Mode(); {
+		case mode.IsDir():
+			dirs = append(dirs, target)
+			return os.MkdirAll(target, mode.Perm())
+		case mode.IsRegular():
+			return linkOrCopy(path, target)
+		case mode&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			plog.Warningf("skipped snapshotting special file %s", path)
+			return nil
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	// 自底向上持久化目录项
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err = FsyncDir(dirs[i]); err != nil {
+			return err
+		}
+	}
+	return FsyncDir(filepath.Dir(filepath.Clean(dst)))
+}
+
+func linkOrCopy(src, dst string) error {
+	err := os.Link(src, dst)
+	if lerr, ok := err.(*os.LinkError); ok && lerr.Err == syscall.EXDEV {
+		return CopyFile(src, dst, &CopyOptions{Sync: true})
+	}
+	return err
+}
+
+// Manifest计算dir下所有普通文件的manifest，按Path排序
+func Manifest(dir string) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		sum, err := sha256File(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, ManifestEntry{
+			Path:   filepath.ToSlash(rel),
+			Size:   info.Size(),
+			Mode:   info.Mode().Perm(),
+			SHA256: sum,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries, nil
+}
+
+// VerifyManifest将dir当前的内容与manifest比对
+func VerifyManifest(dir string, manifest []ManifestEntry) (*ManifestDiff, error) {
+	actual, err := Manifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	want := make(map[string]ManifestEntry, len(manifest))
+	for _, e := range manifest {
+		want[e.Path] = e
+	}
+	diff := &ManifestDiff{}
+	for _, e := range actual {
+		w, ok := want[e.Path]
+		if !ok {
+			diff.Extra = append(diff.Extra, e.Path)
+			continue
+		}
+		delete(want, e.Path)
+		if w != e {
+			diff.Modified = append(diff.Modified, e.Path)
+		}
+	}
+	for p := range want {
+		diff.Missing = append(diff.Missing, p)
+	}
+	sort.Strings(diff.Missing)
+	return diff, nil
+}
+
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
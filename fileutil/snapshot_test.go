@@ -0,0 +1,93 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	for p, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(full, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSnapshotDirManifest(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "src")
+	writeTree(t, src, map[string]string{
+		"a":         "alpha",
+		"sub/b":     "beta",
+		"sub/dir/c": "gamma",
+	})
+	manifest, err := Manifest(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest) != 3 || manifest[1].Path != "sub/b" || manifest[1].Size != 4 {
+		t.Fatalf("manifest = %+v", manifest)
+	}
+
+	dst := filepath.Join(t.TempDir(), "snap")
+	if err = SnapshotDir(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err = SnapshotDir(src, dst); err == nil {
+		t.Error("SnapshotDir to existing dst: err = nil")
+	}
+	diff, err := VerifyManifest(dst, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff.Clean() {
+		t.Errorf("diff = %+v, want clean", diff)
+	}
+}
+
+func TestVerifyManifestDiff(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"keep":     "same",
+		"remove":   "gone soon",
+		"content":  "original",
+		"mode":     "mode",
+		"sub/size": "short",
+	})
+	manifest, err := Manifest(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(filepath.Join(dir, "remove"))
+	writeTree(t, dir, map[string]string{
+		"content":  "0riginal",
+		"sub/size": "much longer",
+		"new":      "extra",
+	})
+	if err = os.Chmod(filepath.Join(dir, "mode"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := VerifyManifest(dir, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &ManifestDiff{
+		Missing:  []string{"remove"},
+		Extra:    []string{"new"},
+		Modified: []string{"content", "mode", "sub/size"},
+	}
+	if !reflect.DeepEqual(diff, want) {
+		t.Errorf("diff = %+v, want %+v", diff, want)
+	}
+	if diff.Clean() {
+		t.Error("Clean() = true")
+	}
+}
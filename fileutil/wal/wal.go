@@ -0,0 +1,421 @@
+// Package wal基于fileutil.Preallocate/Fdatasync与ioutil.PageWriter实现分段的追加写日志
+// 由于ioutil依赖fileutil，该实现放在独立的子包中以避免循环引用
+package wal
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"light-weight-util/fileutil"
+	"light-weight-util/ioutil"
+)
+
+const (
+	// 默认单个segment文件的大小
+	DefaultSegmentBytes = 64 * 1024 * 1024
+	// 默认PageWriter对齐的页大小
+	DefaultPageBytes = 4096
+
+	segmentSuffix = ".seg"
+
+	// 每个segment末尾保留的区域，记录已持久化的偏移: offset(8 bytes LE) | crc32c(4 bytes LE)
+	syncMarkerBytes = 16
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	ErrRecordTooLarge = errors.New("wal: record larger than segment")
+	ErrClosed         = errors.New("wal: log already closed")
+)
+
+// Options配置SegmentedLog 零值字段使用默认值
+type Options struct {
+	SegmentBytes int64
+	PageBytes    int
+}
+
+// SegmentedLog由一组固定大小、预分配的segment文件组成
+// segment文件名为16位十六进制序号，写满后切换到下一个segment
+// 记录通过ioutil.RecordWriter编码，滚动CRC在每个segment内从0开始
+// 当前写入的segment通过fileutil.TryLockFile加锁，防止被其他进程打开或者被PurgeFile删除
+// segment末尾的sync marker记录fsync完成的偏移，Open时只有该偏移之后的数据才可能是torn tail
+type SegmentedLog struct {
+	dir          string
+	segmentBytes int64
+	pageBytes    int
+
+	// syncMu串行化Sync中的fsync，fsync期间不持有mu，Append可以继续写入下一批记录
+	// 需要同时持有时先获取syncMu
+	syncMu sync.Mutex
+
+	mu       sync.Mutex
+	seq      uint64               // 当前segment序号
+	f        *fileutil.LockedFile // 当前segment
+	pw       *ioutil.PageWriter
+	rw       *ioutil.RecordWriter
+	off      int64                // 当前segment中下一条记录的偏移
+	dataEnd  int64                // 当前segment中记录区域的结束偏移，之后为sync marker
+	appended uint64               // 已追加的记录数
+	synced   uint64               // 已持久化的记录数
+	syncing  *fileutil.LockedFile // 正在mu之外fsync的segment
+	retired  *fileutil.LockedFile // roll时仍在fsync的segment，由fsync结束后关闭
+	closed   bool
+}
+
+// Open打开dir下的日志，dir不存在时将被创建
+// 若是已有segment，则扫描最后一个segment找到最后一条合法记录，之后的内容(崩溃时未持久化的记录)被置0
+// 损坏发生在sync marker记录的已持久化偏移之前时返回ioutil.ErrCorruptRecord
+func Open(dir string, opts *Options) (*SegmentedLog, error) {
+	l := &SegmentedLog{dir: dir, segmentBytes: DefaultSegmentBytes, pageBytes: DefaultPageBytes}
+	if opts != nil {
+		if opts.SegmentBytes > syncMarkerBytes {
+			l.segmentBytes = opts.SegmentBytes
+		}
+		if opts.PageBytes > 0 {
+			l.pageBytes = opts.PageBytes
+		}
+	}
+	if err := fileutil.TouchDirAll(dir); err != nil {
+		return nil, err
+	}
+
+	seqs, err := l.segments()
+	if err != nil {
+		return nil, err
+	}
+	if len(seqs) == 0 {
+		if err = l.createSegment(0); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+	if err = l.openTail(seqs[len(seqs)-1]); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Append追加一条记录 记录在Sync之后才保证持久化
+func (l *SegmentedLog) Append(rec []byte) error {
+	size := int64(ioutil.RecordSize(len(rec)))
+	if size > l.segmentBytes-syncMarkerBytes {
+		return ErrRecordTooLarge
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	if l.off+size > l.dataEnd {
+		if err := l.roll(); err != nil {
+			return err
+		}
+	}
+	if _, err := l.rw.Write(rec); err != nil {
+		return err
+	}
+	l.off += size
+	l.appended++
+	return nil
+}
+
+// Sync将调用前已追加的记录持久化
+// fsync在mu之外进行，期间追加的记录由下一次Sync一并持久化；
+// 排队等待的调用者若发现自己的记录已被前一次Sync持久化则直接返回，从而实现group commit
+func (l *SegmentedLog) Sync() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ErrClosed
+	}
+	target := l.appended
+	l.mu.Unlock()
+
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ErrClosed
+	}
+	if l.synced >= target {
+		l.mu.Unlock()
+		return nil
+	}
+	// 在mu中将PageWriter缓冲的数据写入文件，之后追加的记录不影响本次fsync
+	if err := l.pw.Flush(); err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	f, end, off := l.f, l.appended, l.off
+	l.syncing = f
+	l.mu.Unlock()
+
+	err := fileutil.Fdatasync(f.File)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.syncing = nil
+	if err == nil && f == l.f {
+		// marker在fsync完成之后写入，由下一次fsync持久化，因此不会超过真正持久化的偏移
+		// 已切换的segment由roll写入最终的marker
+		err = l.writeSyncMarker(off)
+	}
+	if l.retired != nil {
+		if cerr := l.retired.Close(); err == nil {
+			err = cerr
+		}
+		l.retired = nil
+	}
+	if err != nil {
+		return err
+	}
+	if end > l.synced {
+		l.synced = end
+	}
+	return nil
+}
+
+func (l *SegmentedLog) sync() error {
+	if l.synced == l.appended {
+		return nil
+	}
+	if err := l.pw.Flush(); err != nil {
+		return err
+	}
+	if err := fileutil.Fdatasync(l.f.File); err != nil {
+		return err
+	}
+	l.synced = l.appended
+	return nil
+}
+
+// seal将当前偏移作为最终的sync marker持久化
+func (l *SegmentedLog) seal() error {
+	if err := l.sync(); err != nil {
+		return err
+	}
+	if err := l.writeSyncMarker(l.off); err != nil {
+		return err
+	}
+	return fileutil.Fdatasync(l.f.File)
+}
+
+func (l *SegmentedLog) writeSyncMarker(off int64) error {
+	var b [syncMarkerBytes]byte
+	binary.LittleEndian.PutUint64(b[0:8], uint64(off))
+	binary.LittleEndian.PutUint32(b[8:12], crc32.Checksum(b[0:8], crcTable))
+	_, err := l.f.WriteAt(b[:], l.dataEnd)
+	return err
+}
+
+// Close持久化已追加的记录并释放当前segment的锁
+func (l *SegmentedLog) Close() error {
+	l.syncMu.Lock()
+	defer l.syncMu.Unlock()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	l.closed = true
+	err := l.seal()
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Replay按顺序对每一条合法记录调用fn
+// fn返回error时停止并返回该error
+func (l *SegmentedLog) Replay(fn func(rec []byte) error) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return ErrClosed
+	}
+	if err := l.pw.Flush(); err != nil {
+		return err
+	}
+	seqs, err := l.segments()
+	if err != nil {
+		return err
+	}
+	for _, seq := range seqs {
+		f, err := os.Open(l.segmentPath(seq))
+		if err != nil {
+			return err
+		}
+		_, _, err = scanSegment(f, fn)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roll持久化并关闭当前segment，切换到下一个segment
+// 当前segment剩余的空间在预分配时已经置0，读取时视为segment结束
+func (l *SegmentedLog) roll() error {
+	if err := l.seal(); err != nil {
+		return err
+	}
+	if l.f == l.syncing {
+		// Sync正在mu之外fsync该segment，由其结束后关闭
+		l.retired = l.f
+	} else if err := l.f.Close(); err != nil {
+		return err
+	}
+	return l.createSegment(l.seq + 1)
+}
+
+func (l *SegmentedLog) createSegment(seq uint64) error {
+	p := l.segmentPath(seq)
+	f, err := fileutil.TryLockFile(p, os.O_RDWR|os.O_CREATE|os.O_EXCL, fileutil.FileMode)
+	if err != nil {
+		return err
+	}
+	if err = fileutil.Preallocate(f.File, l.segmentBytes, true); err != nil {
+		f.Close()
+		os.Remove(p)
+		return err
+	}
+	// 持久化新文件的目录项
+	if err = fileutil.FsyncDir(l.dir); err != nil {
+		f.Close()
+		return err
+	}
+	l.setTail(seq, f, 0, 0, l.segmentBytes-syncMarkerBytes)
+	return nil
+}
+
+func (l *SegmentedLog) openTail(seq uint64) error {
+	f, err := fileutil.TryLockFile(l.segmentPath(seq), os.O_RDWR, fileutil.FileMode)
+	if err != nil {
+		return err
+	}
+	end, err := dataEnd(f.File)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	off, crc, err := scanSegment(f.File, nil)
+	if err == nil {
+		// 已持久化的偏移之后到记录区域结束的数据都属于崩溃时未完成的写入
+		err = fileutil.ZeroRange(f.File, off, end-off)
+	}
+	if err == nil {
+		_, err = f.Seek(off, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.setTail(seq, f, off, crc, end)
+	return nil
+}
+
+func (l *SegmentedLog) setTail(seq uint64, f *fileutil.LockedFile, off int64, crc uint32, end int64) {
+	l.seq, l.f, l.off, l.dataEnd = seq, f, off, end
+	l.pw = ioutil.NewPageWriter(f, l.pageBytes, int(off%int64(l.pageBytes)))
+	l.rw = ioutil.NewRecordWriter(l.pw, crc)
+}
+
+// segments返回已排序的segment序号
+func (l *SegmentedLog) segments() ([]uint64, error) {
+	names, err := fileutil.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, name := range names {
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 16, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	return seqs, nil
+}
+
+func (l *SegmentedLog) segmentPath(seq uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%016x%s", seq, segmentSuffix))
+}
+
+// dataEnd返回segment中记录区域的结束偏移
+func dataEnd(f *os.File) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if fi.Size() < syncMarkerBytes {
+		return 0, fmt.Errorf("wal: %s: segment too small (%d bytes)", f.Name(), fi.Size())
+	}
+	return fi.Size() - syncMarkerBytes, nil
+}
+
+// readSyncMarker返回segment中已持久化的偏移 marker不完整(例如写入时崩溃)时返回0
+func readSyncMarker(f *os.File, end int64) (int64, error) {
+	var b [syncMarkerBytes]byte
+	if _, err := f.ReadAt(b[:], end); err != nil {
+		return 0, err
+	}
+	off := int64(binary.LittleEndian.Uint64(b[0:8]))
+	if crc32.Checksum(b[0:8], crcTable) != binary.LittleEndian.Uint32(b[8:12]) || off < 0 || off > end {
+		return 0, nil
+	}
+	return off, nil
+}
+
+// scanSegment从头读取segment，对每一条合法记录调用fn(fn可以为nil)
+// 返回最后一条合法记录结束的偏移及其滚动CRC
+// 读取在sync marker记录的偏移之后停止时，之后的内容视为崩溃时未完成的写入而被忽略；
+// 在该偏移之前停止说明已持久化的记录损坏，返回ioutil.ErrCorruptRecord
+func scanSegment(f *os.File, fn func(rec []byte) error) (int64, uint32, error) {
+	end, err := dataEnd(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	synced, err := readSyncMarker(f, end)
+	if err != nil {
+		return 0, 0, err
+	}
+	rr := ioutil.NewRecordReader(io.NewSectionReader(f, 0, end), 0)
+	for {
+		rec, err := rr.Next()
+		if err == io.EOF || err == ioutil.ErrTornTail || errors.Is(err, ioutil.ErrCorruptRecord) {
+			if rr.Offset() >= synced {
+				return rr.Offset(), rr.CRC(), nil
+			}
+			if !errors.Is(err, ioutil.ErrCorruptRecord) {
+				err = fmt.Errorf("%w at offset %d", ioutil.ErrCorruptRecord, rr.Offset())
+			}
+		}
+		if err != nil {
+			return 0, 0, fmt.Errorf("wal: %s: %w", f.Name(), err)
+		}
+		if fn != nil {
+			if err = fn(rec); err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	lioutil "light-weight-util/ioutil"
+)
+
+func record(i int) []byte {
+	return []byte(fmt.Sprintf("record-%04d", i))
+}
+
+func appendN(t *testing.T, l *SegmentedLog, from, to int) {
+	for i := from; i < to; i++ {
+		if err := l.Append(record(i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func replayAll(t *testing.T, l *SegmentedLog) [][]byte {
+	var recs [][]byte
+	if err := l.Replay(func(rec []byte) error {
+		recs = append(recs, append([]byte(nil), rec...))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return recs
+}
+
+func checkRecords(t *testing.T, recs [][]byte, n int) {
+	t.Helper()
+	if len(recs) != n {
+		t.Fatalf("len(records) = %d, want %d", len(recs), n)
+	}
+	for i, rec := range recs {
+		if !bytes.Equal(rec, record(i)) {
+			t.Fatalf("#%d: record = %q, want %q", i, rec, record(i))
+		}
+	}
+}
+
+func TestRolloverAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, &Options{SegmentBytes: 1024, PageBytes: 512})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 0, 200)
+	checkRecords(t, replayAll(t, l), 200)
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, "*"+segmentSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每条记录24 bytes，每个segment 42条
+	if len(names) != 5 {
+		t.Errorf("segments = %d, want 5", len(names))
+	}
+
+	if l, err = Open(dir, &Options{SegmentBytes: 1024, PageBytes: 512}); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	appendN(t, l, 200, 250)
+	checkRecords(t, replayAll(t, l), 250)
+}
+
+func TestReopenTornTail(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, &Options{SegmentBytes: 64 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 0, 10)
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟崩溃时写入一半的记录: 记录头声明100 bytes，只写入了部分数据
+	off := int64(10 * lioutil.RecordSize(len(record(0))))
+	torn := append([]byte{100, 0, 0, 0, 1, 2, 3, 4}, bytes.Repeat([]byte{'x'}, 30)...)
+	writeAt(t, filepath.Join(dir, fmt.Sprintf("%016x%s", 0, segmentSuffix)), torn, off)
+
+	if l, err = Open(dir, &Options{SegmentBytes: 64 * 1024}); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, replayAll(t, l), 10)
+	appendN(t, l, 10, 11)
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err = Open(dir, &Options{SegmentBytes: 64 * 1024}); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	checkRecords(t, replayAll(t, l), 11)
+}
+
+// crash模拟进程崩溃: 不持久化已追加的记录直接释放segment的锁
+func crash(l *SegmentedLog) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+	l.f.Close()
+}
+
+func TestReopenUnsyncedLargeRecord(t *testing.T) {
+	dir := t.TempDir()
+	opts := &Options{SegmentBytes: 1024 * 1024}
+	l, err := Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 0, 10)
+	if err = l.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	// 大于一页的记录由PageWriter直接写入文件，崩溃时可能只有一部分落盘
+	big := bytes.Repeat([]byte{'b'}, 300*1024)
+	if err = l.Append(big); err != nil {
+		t.Fatal(err)
+	}
+	crash(l)
+
+	// 记录的最后8KiB没有落盘
+	off := int64(10 * lioutil.RecordSize(len(record(0))))
+	end := off + int64(lioutil.RecordSize(len(big)))
+	writeAt(t, filepath.Join(dir, fmt.Sprintf("%016x%s", 0, segmentSuffix)), make([]byte, 8192), end-8192)
+
+	if l, err = Open(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, replayAll(t, l), 10)
+	appendN(t, l, 10, 11)
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if l, err = Open(dir, opts); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	checkRecords(t, replayAll(t, l), 11)
+}
+
+func TestOpenRefusesMidSegmentCorruption(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, &Options{SegmentBytes: 64 * 1024})
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendN(t, l, 0, 101)
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 破坏第3条记录的长度字段
+	p := filepath.Join(dir, fmt.Sprintf("%016x%s", 0, segmentSuffix))
+	writeAt(t, p, []byte{0xff}, int64(2*lioutil.RecordSize(len(record(0)))))
+	before, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = Open(dir, &Options{SegmentBytes: 64 * 1024}); !errors.Is(err, lioutil.ErrCorruptRecord) {
+		t.Fatalf("err = %v, want %v", err, lioutil.ErrCorruptRecord)
+	}
+	after, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("segment modified by failed Open")
+	}
+}
+
+func TestConcurrentAppendSync(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, &Options{SegmentBytes: 4096})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := l.Append([]byte("concurrent")); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := l.Sync(); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	l.mu.Lock()
+	appended, synced := l.appended, l.synced
+	l.mu.Unlock()
+	if synced != appended || appended != 400 {
+		t.Errorf("appended = %d, synced = %d, want 400", appended, synced)
+	}
+	if n := len(replayAll(t, l)); n != 400 {
+		t.Errorf("replayed %d records, want 400", n)
+	}
+	if err = l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = l.Sync(); err != ErrClosed {
+		t.Errorf("err = %v, want %v", err, ErrClosed)
+	}
+}
+
+func writeAt(t *testing.T, path string, b []byte, off int64) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err = f.WriteAt(b, off); err != nil {
+		t.Fatal(err)
+	}
+}
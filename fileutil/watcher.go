@@ -0,0 +1,305 @@
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Op是目录中文件发生的变更类型，防抖合并后可能同时包含多个类型
+type Op uint32
+
+const (
+	Create Op = 1 << iota
+	Write
+	Remove
+	Rename
+)
+
+func (op Op) String() string {
+	var names []string
+	for _, o := range []struct {
+		op   Op
+		name string
+	}{{Create, "CREATE"}, {Write, "WRITE"}, {Remove, "REMOVE"}, {Rename, "RENAME"}} {
+		if op&o.op != 0 {
+			names = append(names, o.name)
+		}
+	}
+	if len(names) == 0 {
+		return "NONE"
+	}
+	return strings.Join(names, "|")
+}
+
+// WatchEvent是目录中一个文件的变更 Name为文件的完整路径
+type WatchEvent struct {
+	Name string
+	Op   Op
+}
+
+const (
+	defaultPollInterval = time.Second
+)
+
+var (
+	// ErrWatchedDirGone表示被监听的目录被删除或者移走，Watcher停止
+	ErrWatchedDirGone = errors.New("fileutil: watched directory removed or moved")
+)
+
+// WatchOptions配置Watcher 零值字段使用默认值
+type WatchOptions struct {
+	// Debounce时间内同一个文件的多次变更合并为一个事件，0表示不合并
+	Debounce time.Duration
+	// Poll强制使用轮询实现
+	Poll bool
+	// PollInterval为轮询实现的扫描间隔，默认1s
+	PollInterval time.Duration
+}
+
+// Watcher监听一个目录下文件的创建/写入/删除/rename
+// Linux上使用inotify，不可用时退化为基于ReadDir的轮询
+// 监听的是目录而非单个文件，因此编辑器通过写临时文件再rename覆盖的方式保存时，目标文件依然能收到Create事件
+type Watcher struct {
+	Events <-chan WatchEvent
+	Errors <-chan error
+
+	dir      string
+	debounce time.Duration
+
+	events chan WatchEvent
+	errc   chan error
+	raw    chan WatchEvent
+
+	stop      chan struct{}
+	donec     chan struct{}
+	closeOnce sync.Once
+	closeFn   func() error
+}
+
+// NewWatcher监听目录dir
+func NewWatcher(dir string, opts *WatchOptions) (*Watcher, error) {
+	var op WatchOptions
+	if opts != nil {
+		op = *opts
+	}
+	if op.PollInterval <= 0 {
+		op.PollInterval = defaultPollInterval
+	}
+
+	w := newWatcher(dir, op.Debounce)
+	if !op.Poll {
+		err := w.startNative()
+		if err == nil {
+			return w, nil
+		}
+		plog.Warningf("inotify unavailable for %s (%v), falling back to polling", dir, err)
+	}
+	if err := w.startPolling(op.PollInterval); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func newWatcher(dir string, debounce time.Duration) *Watcher {
+	w := &Watcher{
+		dir:      dir,
+		debounce: debounce,
+		events:   make(chan WatchEvent, 64),
+		errc:     make(chan error, 8),
+		raw:      make(chan WatchEvent, 64),
+		stop:     make(chan struct{}),
+		donec:    make(chan struct{}),
+	}
+	w.Events, w.Errors = w.events, w.errc
+	return w
+}
+
+// Close停止监听，Events随后被关闭
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.stop)
+		if w.closeFn != nil {
+			err = w.closeFn()
+		}
+		<-w.donec
+	})
+	return err
+}
+
+// send由后端调用，将原始事件交给防抖goroutine
+func (w *Watcher) send(ev WatchEvent) bool {
+	select {
+	case w.raw <- ev:
+		return true
+	case <-w.stop:
+		return false
+	}
+}
+
+// sendErr由后端调用 Errors已满时丢弃
+func (w *Watcher) sendErr(err error) {
+	select {
+	case w.errc <- err:
+	default:
+		plog.Errorf("dropped watcher error for %s (%v)", w.dir, err)
+	}
+}
+
+type pendingEvent struct {
+	op       Op
+	deadline time.Time
+}
+
+// debounceLoop合并raw中的事件后发送到Events 后端关闭raw时退出
+func (w *Watcher) debounceLoop() {
+	defer close(w.donec)
+	defer close(w.events)
+
+	pending := make(map[string]*pendingEvent)
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	emit := func(ev WatchEvent) bool {
+		select {
+		case w.events <- ev:
+			return true
+		case <-w.stop:
+			return false
+		}
+	}
+	// 发送所有到期的事件并返回最近的截止时间
+	flush := func(now time.Time, all bool) (time.Time, bool) {
+		var next time.Time
+		for name, p := range pending {
+			if all || !p.deadline.After(now) {
+				if !emit(WatchEvent{Name: name, Op: p.op}) {
+					return next, false
+				}
+				delete(pending, name)
+			} else if next.IsZero() || p.deadline.Before(next) {
+				next = p.deadline
+			}
+		}
+		return next, true
+	}
+
+	for {
+		select {
+		case ev, ok := <-w.raw:
+			if !ok {
+				flush(time.Now(), true)
+				return
+			}
+			if w.debounce <= 0 {
+				if !emit(ev) {
+					return
+				}
+				continue
+			}
+			p, ok := pending[ev.Name]
+			if !ok {
+				p = &pendingEvent{}
+				pending[ev.Name] = p
+			}
+			p.op |= ev.Op
+			p.deadline = time.Now().Add(w.debounce)
+			if len(pending) == 1 {
+				timer.Reset(w.debounce)
+			}
+		case now := <-timer.C:
+			next, ok := flush(now, false)
+			if !ok {
+				return
+			}
+			if !next.IsZero() {
+				timer.Reset(next.Sub(now))
+			}
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+type pollState struct {
+	size    int64
+	modTime time.Time
+}
+
+// startPolling启动基于ReadDir的轮询后端
+// 轮询无法识别rename，表现为一个Remove加一个Create
+func (w *Watcher) startPolling(interval time.Duration) error {
+	prev, err := w.pollDir()
+	if err != nil {
+		return err
+	}
+
+	go w.debounceLoop()
+	go func() {
+		defer close(w.raw)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+			case <-w.stop:
+				return
+			}
+
+			cur, err := w.pollDir()
+			if err != nil {
+				if os.IsNotExist(err) {
+					w.sendErr(ErrWatchedDirGone)
+					return
+				}
+				w.sendErr(err)
+				continue
+			}
+			for name, st := range cur {
+				old, ok := prev[name]
+				var op Op
+				switch {
+				case !ok:
+					op = Create
+				case old != st:
+					op = Write
+				default:
+					continue
+				}
+				if !w.send(WatchEvent{Name: filepath.Join(w.dir, name), Op: op}) {
+					return
+				}
+			}
+			for name := range prev {
+				if _, ok := cur[name]; !ok {
+					if !w.send(WatchEvent{Name: filepath.Join(w.dir, name), Op: Remove}) {
+						return
+					}
+				}
+			}
+			prev = cur
+		}
+	}()
+	return nil
+}
+
+func (w *Watcher) pollDir() (map[string]pollState, error) {
+	names, err := ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	st := make(map[string]pollState, len(names))
+	for _, name := range names {
+		fi, err := os.Lstat(filepath.Join(w.dir, name))
+		if err != nil {
+			// 扫描期间被删除
+			continue
+		}
+		st[name] = pollState{size: fi.Size(), modTime: fi.ModTime()}
+	}
+	return st, nil
+}
@@ -0,0 +1,92 @@
+// +build linux
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_MODIFY | syscall.IN_CLOSE_WRITE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO |
+	syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+// startNative启动inotify后端
+func (w *Watcher) startNative() error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	if _, err = syscall.InotifyAddWatch(fd, w.dir, inotifyMask|syscall.IN_ONLYDIR); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+	// 非阻塞fd交给runtime poller，Close可以打断阻塞中的Read
+	f := os.NewFile(uintptr(fd), "inotify")
+	w.closeFn = f.Close
+
+	go w.debounceLoop()
+	go w.readInotify(f)
+	return nil
+}
+
+func (w *Watcher) readInotify(f *os.File) {
+	defer close(w.raw)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := f.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				w.sendErr(err)
+			}
+			return
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameStart := off + syscall.SizeofInotifyEvent
+			off = nameStart + int(ev.Len)
+
+			switch mask := ev.Mask; {
+			case mask&syscall.IN_Q_OVERFLOW != 0:
+				w.sendErr(errors.New("fileutil: inotify event queue overflow"))
+				continue
+			case mask&(syscall.IN_DELETE_SELF|syscall.IN_MOVE_SELF|syscall.IN_IGNORED) != 0:
+				w.sendErr(ErrWatchedDirGone)
+				return
+			}
+
+			name := strings.TrimRight(string(buf[nameStart:off]), "\x00")
+			if name == "" {
+				continue
+			}
+			if op := inotifyOp(ev.Mask); op != 0 {
+				if !w.send(WatchEvent{Name: filepath.Join(w.dir, name), Op: op}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func inotifyOp(mask uint32) Op {
+	var op Op
+	if mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+		op |= Create
+	}
+	if mask&(syscall.IN_MODIFY|syscall.IN_CLOSE_WRITE) != 0 {
+		op |= Write
+	}
+	if mask&syscall.IN_DELETE != 0 {
+		op |= Remove
+	}
+	if mask&syscall.IN_MOVED_FROM != 0 {
+		op |= Rename
+	}
+	return op
+}
@@ -0,0 +1,121 @@
+package fileutil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// collectEvents收集Events直到quiet时间内没有新的事件，按文件名合并Op并记录事件数
+func collectEvents(t *testing.T, w *Watcher, quiet time.Duration) (map[string]Op, map[string]int) {
+	ops, counts := make(map[string]Op), make(map[string]int)
+	timer := time.NewTimer(quiet)
+	defer timer.Stop()
+	for {
+		select {
+		case ev := <-w.Events:
+			ops[filepath.Base(ev.Name)] |= ev.Op
+			counts[filepath.Base(ev.Name)]++
+			timer.Reset(quiet)
+		case err := <-w.Errors:
+			t.Fatal(err)
+		case <-timer.C:
+			return ops, counts
+		}
+	}
+}
+
+func TestWatcherDebounce(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher(dir, &WatchOptions{Debounce: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	p := filepath.Join(dir, "data")
+	for i := 0; i < 10; i++ {
+		if err = ioutil.WriteFile(p, []byte{byte(i)}, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ops, counts := collectEvents(t, w, 300*time.Millisecond)
+	if counts["data"] != 1 {
+		t.Errorf("events = %d, want 1 after debounce", counts["data"])
+	}
+	if ops["data"]&(Create|Write) != Create|Write {
+		t.Errorf("op = %v, want CREATE|WRITE", ops["data"])
+	}
+}
+
+func TestWatcherAtomicRenameSave(t *testing.T) {
+	for _, poll := range []bool{false, true} {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "config")
+		if err := ioutil.WriteFile(target, []byte("v1"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		w, err := NewWatcher(dir, &WatchOptions{Poll: poll, PollInterval: 20 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 编辑器的保存方式: 写临时文件，再rename覆盖目标文件
+		if err = WriteFileAtomic(target, []byte("v2 longer"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		ops, _ := collectEvents(t, w, 200*time.Millisecond)
+		w.Close()
+
+		// 轮询时rename覆盖表现为Write(大小或者mtime变化)
+		want := Create
+		if poll {
+			want = Write
+		}
+		if ops["config"]&want == 0 {
+			t.Errorf("poll=%v: op = %v, want %v", poll, ops["config"], want)
+		}
+	}
+}
+
+func TestWatcherPolling(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher(dir, &WatchOptions{Poll: true, PollInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	p := filepath.Join(dir, "f")
+	steps := []struct {
+		do   func() error
+		want Op
+	}{
+		{func() error { return ioutil.WriteFile(p, []byte("a"), 0600) }, Create},
+		{func() error { return ioutil.WriteFile(p, []byte("abc"), 0600) }, Write},
+		{func() error { return os.Remove(p) }, Remove},
+	}
+	for i, s := range steps {
+		if err = s.do(); err != nil {
+			t.Fatal(err)
+		}
+		ops, _ := collectEvents(t, w, 100*time.Millisecond)
+		if ops["f"] != s.want {
+			t.Errorf("step %d: op = %v, want %v", i, ops["f"], s.want)
+		}
+	}
+
+	if err = os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-w.Errors:
+		if err != ErrWatchedDirGone {
+			t.Errorf("err = %v, want %v", err, ErrWatchedDirGone)
+		}
+	case <-time.After(time.Second):
+		t.Error("no error after removing watched dir")
+	}
+}
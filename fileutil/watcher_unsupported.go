@@ -0,0 +1,9 @@
+// +build !linux
+
+package fileutil
+
+import "errors"
+
+func (w *Watcher) startNative() error {
+	return errors.New("fileutil: native file watching is not supported on this platform")
+}
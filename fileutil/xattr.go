@@ -0,0 +1,170 @@
+package fileutil
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// 仅使用user.*命名空间 普通用户即可读写
+const xattrPrefix = "user."
+
+var (
+	// ErrXattrNotSupported表示文件系统不支持扩展属性(例如未开启xattr的tmpfs)
+	ErrXattrNotSupported = errors.New("fileutil: extended attributes not supported")
+	// ErrXattrNotFound表示扩展属性不存在
+	ErrXattrNotFound = errors.New("fileutil: extended attribute not found")
+)
+
+// GetXattr读取path上名为user.<name>的扩展属性
+func GetXattr(path, name string) ([]byte, error) {
+	return getxattr(path, xattrPrefix+name)
+}
+
+// SetXattr设置path上名为user.<name>的扩展属性
+func SetXattr(path, name string, value []byte) error {
+	return setxattr(path, xattrPrefix+name, value)
+}
+
+// ListXattr返回path上所有user.*扩展属性的名称(不含"user."前缀)
+func ListXattr(path string) ([]string, error) {
+	names, err := listxattr(path)
+	if err != nil {
+		return nil, err
+	}
+	var user []string
+	for _, n := range names {
+		if len(n) > len(xattrPrefix) && n[:len(xattrPrefix)] == xattrPrefix {
+			user = append(user, n[len(xattrPrefix):])
+		}
+	}
+	return user, nil
+}
+
+// RemoveXattr删除path上名为user.<name>的扩展属性
+func RemoveXattr(path, name string) error {
+	return removexattr(path, xattrPrefix+name)
+}
+
+// SetXattrs将结构体v(或其指针)中带有`xattr:"name"`标签的字段分别写入扩展属性
+// 支持string、[]byte、bool以及整数类型的字段，数值以十进制文本保存
+func SetXattrs(path string, v interface{}) error {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("fileutil: SetXattrs expects a struct, got %T", v)
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, err := xattrName(rt.Field(i))
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+		b, err := encodeXattr(rv.Field(i))
+		if err != nil {
+			return fmt.Errorf("fileutil: xattr %q: %v", name, err)
+		}
+		if err = SetXattr(path, name, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetXattrs读取扩展属性填充结构体指针v中带有`xattr:"name"`标签的字段
+// 不存在的扩展属性对应的字段保持不变
+func GetXattrs(path string, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("fileutil: GetXattrs expects a pointer to struct, got %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, err := xattrName(rt.Field(i))
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			continue
+		}
+		b, err := GetXattr(path, name)
+		if err == ErrXattrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err = decodeXattr(rv.Field(i), b); err != nil {
+			return fmt.Errorf("fileutil: xattr %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// xattrName返回字段标签中的扩展属性名称，没有标签时返回""
+// 未导出的字段无法通过反射读写，带有标签时返回error
+func xattrName(f reflect.StructField) (string, error) {
+	name := f.Tag.Get("xattr")
+	if name == "" || name == "-" {
+		return "", nil
+	}
+	if f.PkgPath != "" {
+		return "", fmt.Errorf("fileutil: xattr %q: field %s is unexported", name, f.Name)
+	}
+	return name, nil
+}
+
+func encodeXattr(v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return []byte(v.String()), nil
+	case reflect.Bool:
+		return []byte(strconv.FormatBool(v.Bool())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return []byte(strconv.FormatInt(v.Int(), 10)), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return []byte(strconv.FormatUint(v.Uint(), 10)), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func decodeXattr(v reflect.Value, b []byte) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(string(b))
+	case reflect.Bool:
+		x, err := strconv.ParseBool(string(b))
+		if err != nil {
+			return err
+		}
+		v.SetBool(x)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x, err := strconv.ParseInt(string(b), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x, err := strconv.ParseUint(string(b), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(x)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.SetBytes(append([]byte(nil), b...))
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
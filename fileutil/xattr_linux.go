@@ -0,0 +1,76 @@
+// +build linux
+
+package fileutil
+
+import (
+	"bytes"
+	"os"
+	"syscall"
+)
+
+func getxattr(path, name string) ([]byte, error) {
+	for {
+		sz, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, xattrError("getxattr", path, err)
+		}
+		buf := make([]byte, sz)
+		n, err := syscall.Getxattr(path, name, buf)
+		if err == syscall.ERANGE {
+			// 两次调用之间属性被修改
+			continue
+		}
+		if err != nil {
+			return nil, xattrError("getxattr", path, err)
+		}
+		return buf[:n], nil
+	}
+}
+
+func setxattr(path, name string, value []byte) error {
+	return xattrError("setxattr", path, syscall.Setxattr(path, name, value, 0))
+}
+
+func listxattr(path string) ([]string, error) {
+	for {
+		sz, err := syscall.Listxattr(path, nil)
+		if err != nil {
+			return nil, xattrError("listxattr", path, err)
+		}
+		if sz == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, sz)
+		n, err := syscall.Listxattr(path, buf)
+		if err == syscall.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, xattrError("listxattr", path, err)
+		}
+		// 名称之间以'\0'分隔
+		var names []string
+		for _, b := range bytes.Split(buf[:n], []byte{0}) {
+			if len(b) != 0 {
+				names = append(names, string(b))
+			}
+		}
+		return names, nil
+	}
+}
+
+func removexattr(path, name string) error {
+	return xattrError("removexattr", path, syscall.Removexattr(path, name))
+}
+
+func xattrError(op, path string, err error) error {
+	switch err {
+	case nil:
+		return nil
+	case syscall.ENOTSUP:
+		return ErrXattrNotSupported
+	case syscall.ENODATA:
+		return ErrXattrNotFound
+	}
+	return &os.PathError{Op: op, Path: path, Err: err}
+}
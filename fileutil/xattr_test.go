@@ -0,0 +1,76 @@
+package fileutil
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type xattrMeta struct {
+	Checksum []byte `xattr:"checksum"`
+	Term     uint64 `xattr:"term"`
+	Index    int32  `xattr:"index"`
+	Final    bool   `xattr:"final"`
+	Node     string `xattr:"node"`
+	Ignored  string
+}
+
+func tempXattrFile(t *testing.T) string {
+	p := filepath.Join(t.TempDir(), "f")
+	if err := ioutil.WriteFile(p, nil, FileMode); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetXattr(p, "probe", []byte("1")); err == ErrXattrNotSupported {
+		t.Skip(err)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestXattrStructRoundTrip(t *testing.T) {
+	p := tempXattrFile(t)
+	in := xattrMeta{Checksum: []byte{1, 2, 3}, Term: 7, Index: -3, Final: true, Node: "n1", Ignored: "x"}
+	if err := SetXattrs(p, &in); err != nil {
+		t.Fatal(err)
+	}
+
+	var out xattrMeta
+	if err := GetXattrs(p, &out); err != nil {
+		t.Fatal(err)
+	}
+	in.Ignored = ""
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+
+	if err := RemoveXattr(p, "node"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetXattr(p, "node"); err != ErrXattrNotFound {
+		t.Errorf("err = %v, want %v", err, ErrXattrNotFound)
+	}
+	if b, err := GetXattr(p, "checksum"); err != nil || !bytes.Equal(b, in.Checksum) {
+		t.Errorf("checksum = %v, %v", b, err)
+	}
+}
+
+func TestXattrUnexportedField(t *testing.T) {
+	type meta struct {
+		node string `xattr:"node"`
+	}
+	p := filepath.Join(t.TempDir(), "f")
+	if err := ioutil.WriteFile(p, nil, FileMode); err != nil {
+		t.Fatal(err)
+	}
+
+	var m meta
+	if err := SetXattrs(p, m); err == nil {
+		t.Error("SetXattrs: err = nil, want error")
+	}
+	if err := GetXattrs(p, &m); err == nil {
+		t.Error("GetXattrs: err = nil, want error")
+	}
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package fileutil
+
+func getxattr(path, name string) ([]byte, error) { return nil, ErrXattrNotSupported }
+
+func setxattr(path, name string, value []byte) error { return ErrXattrNotSupported }
+
+func listxattr(path string) ([]string, error) { return nil, ErrXattrNotSupported }
+
+func removexattr(path, name string) error { return ErrXattrNotSupported }
@@ -0,0 +1,160 @@
+package ioutil
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+var (
+	ErrAsyncWriterClosed = fmt.Errorf("ioutil: async page writer closed")
+)
+
+// AsyncPageWriter与PageWriter一样保证刷盘时页面对齐，但使用两块buffer交替写入:
+// 当前buffer超过watermark时，将其中整页的部分交给后台goroutine写入底层io.Writer，调用者继续写另一块buffer
+// 后台写入出错后，该错误在之后的Write/FlushAndWait/Close中返回
+// 与PageWriter相同，AsyncPageWriter不支持并发调用
+type AsyncPageWriter struct {
+	w                 io.Writer
+	pageBytes         int
+	pageOffset        int    // 当前buffer起始位置在page中的offset
+	bufWatermarkBytes int    // buffer中超过该byte数时交给后台写入
+	active            []byte // 当前写入的buffer
+
+	spare  chan []byte // 空闲的buffer
+	flushc chan asyncFlush
+	donec  chan struct{}
+
+	mu     sync.Mutex
+	err    error
+	closed bool
+}
+
+type asyncFlush struct {
+	buf  []byte
+	done chan struct{}
+}
+
+// NewAsyncPageWriter创建AsyncPageWriter，bufferBytes<=0时使用defaultBufferBytes
+// bufferBytes被向上取整到pageBytes的整数倍
+func NewAsyncPageWriter(w io.Writer, pageBytes, pageOffset, bufferBytes int) *AsyncPageWriter {
+	if bufferBytes <= 0 {
+		bufferBytes = defaultBufferBytes
+	}
+	bufferBytes = (bufferBytes + pageBytes - 1) / pageBytes * pageBytes
+
+	pw := &AsyncPageWriter{
+		w:                 w,
+		pageBytes:         pageBytes,
+		pageOffset:        pageOffset % pageBytes,
+		bufWatermarkBytes: bufferBytes,
+		spare:             make(chan []byte, 2),
+		flushc:            make(chan asyncFlush),
+		donec:             make(chan struct{}),
+	}
+	// 预留slack空间，保证切分到页边界后剩余的不完整页可以放进另一块buffer
+	pw.active = make([]byte, 0, bufferBytes+pageBytes)
+	pw.spare <- make([]byte, 0, bufferBytes+pageBytes)
+	go pw.run()
+	return pw
+}
+
+func (pw *AsyncPageWriter) Write(p []byte) (n int, err error) {
+	if err = pw.check(); err != nil {
+		return 0, err
+	}
+	for len(p) > 0 {
+		c := copy(pw.active[len(pw.active):cap(pw.active)], p)
+		pw.active = pw.active[:len(pw.active)+c]
+		p = p[c:]
+		n += c
+		if len(pw.active) >= pw.bufWatermarkBytes {
+			if err = pw.swap(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// swap将当前buffer中到页边界为止的数据交给后台写入，不完整的页拷贝到另一块buffer中继续写入
+func (pw *AsyncPageWriter) swap() error {
+	cut := len(pw.active) - (pw.pageOffset+len(pw.active))%pw.pageBytes
+	next := <-pw.spare
+	next = append(next[:0], pw.active[cut:]...)
+
+	pw.flushc <- asyncFlush{buf: pw.active[:cut]}
+	pw.active = next
+	pw.pageOffset = 0
+	return pw.check()
+}
+
+// FlushAndWait将buffer中的全部数据(包括不完整的页)写入底层io.Writer并等待完成
+func (pw *AsyncPageWriter) FlushAndWait() error {
+	if err := pw.check(); err != nil {
+		return err
+	}
+	return pw.flushAndWait()
+}
+
+func (pw *AsyncPageWriter) flushAndWait() error {
+	done := make(chan struct{})
+	n := len(pw.active)
+	pw.flushc <- asyncFlush{buf: pw.active, done: done}
+	<-done
+
+	pw.pageOffset = (pw.pageOffset + n) % pw.pageBytes
+	pw.active = (<-pw.spare)[:0]
+	return pw.loadErr()
+}
+
+// Close写入剩余的数据并停止后台goroutine
+func (pw *AsyncPageWriter) Close() error {
+	pw.mu.Lock()
+	if pw.closed {
+		pw.mu.Unlock()
+		return ErrAsyncWriterClosed
+	}
+	pw.closed = true
+	pw.mu.Unlock()
+
+	err := pw.flushAndWait()
+	close(pw.flushc)
+	<-pw.donec
+	return err
+}
+
+func (pw *AsyncPageWriter) check() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	if pw.closed {
+		return ErrAsyncWriterClosed
+	}
+	return pw.err
+}
+
+func (pw *AsyncPageWriter) loadErr() error {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+	return pw.err
+}
+
+// run在后台依次写入交过来的buffer，写完后归还buffer
+// 出错后不再写入之后的数据，避免产生空洞
+func (pw *AsyncPageWriter) run() {
+	defer close(pw.donec)
+	for f := range pw.flushc {
+		if err := pw.loadErr(); err == nil && len(f.buf) > 0 {
+			_, err = pw.w.Write(f.buf)
+			if err != nil {
+				pw.mu.Lock()
+				pw.err = err
+				pw.mu.Unlock()
+			}
+		}
+		pw.spare <- f.buf[:0]
+		if f.done != nil {
+			close(f.done)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// recordWriter记录每次Write的数据
+type recordWriter struct {
+	mu     sync.Mutex
+	writes [][]byte
+}
+
+func (rw *recordWriter) Write(p []byte) (int, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.writes = append(rw.writes, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+func (rw *recordWriter) count() int {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return len(rw.writes)
+}
+
+func TestAsyncPageWriterRandom(t *testing.T) {
+	const pageBytes = 128
+	rnd := rand.New(rand.NewSource(1))
+	for iter := 0; iter < 50; iter++ {
+		pageOffset := rnd.Intn(pageBytes)
+		bufferBytes := pageBytes * (1 + rnd.Intn(8))
+		rw := &recordWriter{}
+		pw := NewAsyncPageWriter(rw, pageBytes, pageOffset, bufferBytes)
+
+		var want []byte
+		// FlushAndWait/Close产生的最后一次写入可以不在页边界结束
+		flushed := map[int]bool{}
+		for i := 0; i < 100; i++ {
+			p := make([]byte, rnd.Intn(3*bufferBytes))
+			rnd.Read(p)
+			if n, err := pw.Write(p); err != nil || n != len(p) {
+				t.Fatalf("write: n = %d, err = %v", n, err)
+			}
+			want = append(want, p...)
+			if rnd.Intn(10) == 0 {
+				if err := pw.FlushAndWait(); err != nil {
+					t.Fatal(err)
+				}
+				flushed[rw.count()-1] = true
+			}
+		}
+		if err := pw.Close(); err != nil {
+			t.Fatal(err)
+		}
+		flushed[rw.count()-1] = true
+
+		off := pageOffset
+		var got []byte
+		for i, w := range rw.writes {
+			got = append(got, w...)
+			off += len(w)
+			if off%pageBytes != 0 && !flushed[i] {
+				t.Fatalf("iter %d: write #%d (%d bytes) ends at page offset %d", iter, i, len(w), off%pageBytes)
+			}
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("iter %d: output mismatch (%d bytes, want %d)", iter, len(got), len(want))
+		}
+	}
+}
+
+type failingWriter struct {
+	n   int // 允许写入的byte数
+	err error
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > fw.n {
+		n := fw.n
+		fw.n = 0
+		return n, fw.err
+	}
+	fw.n -= len(p)
+	return len(p), nil
+}
+
+func TestAsyncPageWriterError(t *testing.T) {
+	werr := errors.New("disk full")
+	pw := NewAsyncPageWriter(&failingWriter{n: 4096, err: werr}, 512, 0, 1024)
+
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		_, err = pw.Write(make([]byte, 700))
+	}
+	if err != werr {
+		t.Fatalf("Write err = %v, want %v", err, werr)
+	}
+	if err = pw.FlushAndWait(); err != werr {
+		t.Errorf("FlushAndWait err = %v, want %v", err, werr)
+	}
+	if err = pw.Close(); err != werr {
+		t.Errorf("Close err = %v, want %v", err, werr)
+	}
+}
+
+func TestAsyncPageWriterUseAfterClose(t *testing.T) {
+	rw := &recordWriter{}
+	pw := NewAsyncPageWriter(rw, 512, 0, 0)
+	pw.Write([]byte("hello"))
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rw.writes) != 1 || string(rw.writes[0]) != "hello" {
+		t.Errorf("writes = %q", rw.writes)
+	}
+
+	if _, err := pw.Write([]byte("x")); err != ErrAsyncWriterClosed {
+		t.Errorf("Write err = %v, want %v", err, ErrAsyncWriterClosed)
+	}
+	if err := pw.FlushAndWait(); err != ErrAsyncWriterClosed {
+		t.Errorf("FlushAndWait err = %v, want %v", err, ErrAsyncWriterClosed)
+	}
+	if err := pw.Close(); err != ErrAsyncWriterClosed {
+		t.Errorf("Close err = %v, want %v", err, ErrAsyncWriterClosed)
+	}
+}
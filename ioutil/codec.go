@@ -0,0 +1,173 @@
+package ioutil
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// 压缩流的头部: 4 bytes magic + 1 byte codec ID，读取时据此自动选择codec
+var codecMagic = []byte("LWCZ")
+
+const codecHeaderBytes = 5
+
+var (
+	ErrUnknownCodec  = fmt.Errorf("ioutil: unknown compression codec")
+	ErrBadCodecMagic = fmt.Errorf("ioutil: missing compression header")
+)
+
+// Codec是一种压缩算法 ID写入流的头部，注册后不能修改
+// 内置gzip(1)、zlib(2)、flate(3)；snappy、zstd等可以通过RegisterCodec接入
+type Codec interface {
+	Name() string
+	ID() byte
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+	NewReader(r io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	codecMu     sync.RWMutex
+	codecByID   = make(map[byte]Codec)
+	codecByName = make(map[string]Codec)
+)
+
+func init() {
+	for _, c := range []Codec{gzipCodec{}, zlibCodec{}, flateCodec{}} {
+		if err := RegisterCodec(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterCodec注册codec，名称或者ID已被占用时返回error
+func RegisterCodec(c Codec) error {
+	codecMu.Lock()
+	defer codecMu.Unlock()
+
+	if old, ok := codecByID[c.ID()]; ok {
+		return fmt.Errorf("ioutil: codec id %d already registered by %q", c.ID(), old.Name())
+	}
+	if _, ok := codecByName[c.Name()]; ok {
+		return fmt.Errorf("ioutil: codec %q already registered", c.Name())
+	}
+	codecByID[c.ID()] = c
+	codecByName[c.Name()] = c
+	return nil
+}
+
+// LookupCodec按名称查找已注册的codec
+func LookupCodec(name string) (Codec, bool) {
+	codecMu.RLock()
+	defer codecMu.RUnlock()
+	c, ok := codecByName[name]
+	return c, ok
+}
+
+// NewCompressWriter写入头部后返回按codec name压缩的Writer
+// Close刷新压缩数据，但不关闭w
+func NewCompressWriter(w io.Writer, name string) (io.WriteCloser, error) {
+	c, ok := LookupCodec(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownCodec, name)
+	}
+	hdr := append(append(make([]byte, 0, codecHeaderBytes), codecMagic...), c.ID())
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return c.NewWriter(w)
+}
+
+// NewDecompressReader读取头部，自动选择codec解压r
+func NewDecompressReader(r io.Reader) (io.ReadCloser, error) {
+	var hdr [codecHeaderBytes]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBadCodecMagic
+		}
+		return nil, err
+	}
+	if !bytes.Equal(hdr[:len(codecMagic)], codecMagic) {
+		return nil, ErrBadCodecMagic
+	}
+
+	codecMu.RLock()
+	c, ok := codecByID[hdr[len(codecMagic)]]
+	codecMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: id %d", ErrUnknownCodec, hdr[len(codecMagic)])
+	}
+	return c.NewReader(r)
+}
+
+// NewDecompressReadCloser解压rc，并与NewExactReadCloser一样校验解压后的大小为totalBytes:
+// 解压后的数据超过totalBytes时Read返回ErrExpectEOF，不足时返回ErrShortRead
+// Close同时关闭解压器与rc
+func NewDecompressReadCloser(rc io.ReadCloser, totalBytes int64) (io.ReadCloser, error) {
+	dr, err := NewDecompressReader(rc)
+	if err != nil {
+		return nil, err
+	}
+	return NewExactReadCloser(&ReaderAndCloser{Reader: deferEOF{dr}, Closer: multiCloser{dr, rc}}, totalBytes), nil
+}
+
+// deferEOF将与数据一起返回的io.EOF推迟到下一次Read
+// exactReadCloser只在读到0 byte时检查长度是否不足，解压器通常在返回最后的数据时一并返回io.EOF
+type deferEOF struct{ r io.Reader }
+
+func (d deferEOF) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// multiCloser依次关闭，返回第一个error
+type multiCloser []io.Closer
+
+func (mc multiCloser) Close() error {
+	var err error
+	for _, c := range mc {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return "gzip" }
+func (gzipCodec) ID() byte     { return 1 }
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriter(w), nil
+}
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+type zlibCodec struct{}
+
+func (zlibCodec) Name() string { return "zlib" }
+func (zlibCodec) ID() byte     { return 2 }
+func (zlibCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zlib.NewWriter(w), nil
+}
+func (zlibCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return zlib.NewReader(r)
+}
+
+type flateCodec struct{}
+
+func (flateCodec) Name() string { return "flate" }
+func (flateCodec) ID() byte     { return 3 }
+func (flateCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return flate.NewWriter(w, flate.DefaultCompression)
+}
+func (flateCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
@@ -0,0 +1,99 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func compress(t *testing.T, name string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewCompressWriter(&buf, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("light-weight-util "), 1000)
+	for _, name := range []string{"gzip", "zlib", "flate"} {
+		c, ok := LookupCodec(name)
+		if !ok {
+			t.Fatalf("codec %q not registered", name)
+		}
+		z := compress(t, name, data)
+		if !bytes.Equal(z[:4], codecMagic) || z[4] != c.ID() {
+			t.Errorf("%s: header = %v", name, z[:codecHeaderBytes])
+		}
+		r, err := NewDecompressReader(bytes.NewReader(z))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: decompressed data mismatch", name)
+		}
+	}
+}
+
+func TestCodecUnknown(t *testing.T) {
+	if _, err := NewCompressWriter(ioutil.Discard, "no-such-codec"); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("NewCompressWriter: err = %v, want %v", err, ErrUnknownCodec)
+	}
+	hdr := append(append([]byte{}, codecMagic...), 0xff)
+	if _, err := NewDecompressReader(bytes.NewReader(hdr)); !errors.Is(err, ErrUnknownCodec) {
+		t.Errorf("NewDecompressReader: err = %v, want %v", err, ErrUnknownCodec)
+	}
+	if err := RegisterCodec(gzipCodec{}); err == nil {
+		t.Error("registering a duplicate codec should fail")
+	}
+}
+
+func TestCodecBadMagic(t *testing.T) {
+	for _, in := range [][]byte{nil, []byte("LWC"), []byte("XXXX\x01data")} {
+		if _, err := NewDecompressReader(bytes.NewReader(in)); err != ErrBadCodecMagic {
+			t.Errorf("%q: err = %v, want %v", in, err, ErrBadCodecMagic)
+		}
+	}
+}
+
+func TestDecompressReadCloserSize(t *testing.T) {
+	data := bytes.Repeat([]byte{'a'}, 1024)
+	z := compress(t, "zlib", data)
+
+	tests := []struct {
+		totalBytes int64
+		readErr    error
+		closeErr   error
+	}{
+		{int64(len(data)), nil, nil},
+		{int64(len(data)) + 1, ErrShortRead, ErrShortRead},
+		{int64(len(data)) - 1, ErrExpectEOF, nil},
+	}
+	for i, tt := range tests {
+		rc, err := NewDecompressReadCloser(ioutil.NopCloser(bytes.NewReader(z)), tt.totalBytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.Copy(ioutil.Discard, rc)
+		if err != tt.readErr {
+			t.Errorf("#%d: read err = %v, want %v", i, err, tt.readErr)
+		}
+		if err = rc.Close(); err != tt.closeErr {
+			t.Errorf("#%d: close err = %v, want %v", i, err, tt.closeErr)
+		}
+	}
+}
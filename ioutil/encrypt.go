@@ -0,0 +1,332 @@
+package ioutil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// 加密流格式(STREAM构造, AES-256-GCM):
+//
+//	header: magic(4) | version(1) | chunkBytes(4, BE) | salt(32) | noncePrefix(7) | keyIDLen(1) | keyID
+//	chunk:  ciphertext(<= chunkBytes) | tag(16)
+//
+// 每个流使用HKDF-SHA256(key, salt)派生的子密钥，长期密钥下不同的流不会出现nonce重用
+// 每个chunk的nonce = noncePrefix | counter(4, BE) | last(1)，header作为每个chunk的附加数据
+// counter保证chunk不能被重排，last标记保证流不能在chunk边界被截断
+var encryptMagic = []byte("LWEC")
+
+const (
+	encryptVersion     = 1
+	encryptSaltBytes   = 32
+	encryptPrefixBytes = 7
+	encryptFixedBytes  = 4 + 1 + 4 + encryptSaltBytes + encryptPrefixBytes + 1
+
+	// EncryptChunkBytes是每个chunk的明文byte数
+	EncryptChunkBytes = 64 * 1024
+	// EncryptKeyBytes是AES-256密钥的byte数
+	EncryptKeyBytes = 32
+)
+
+var (
+	ErrInvalidKeySize      = fmt.Errorf("ioutil: encryption key must be %d bytes", EncryptKeyBytes)
+	ErrBadEncryptHeader    = fmt.Errorf("ioutil: invalid encryption header")
+	ErrDecrypt             = fmt.Errorf("ioutil: message authentication failed")
+	ErrTruncatedStream     = fmt.Errorf("ioutil: encrypted stream truncated")
+	ErrEncryptStreamLimit  = fmt.Errorf("ioutil: encrypted stream too long")
+	ErrEncryptWriterClosed = fmt.Errorf("ioutil: encrypt writer already closed")
+)
+
+// KeyFunc按header中记录的key ID返回解密密钥，用于密钥轮换
+type KeyFunc func(keyID string) ([]byte, error)
+
+var encryptHKDFInfo = []byte("light-weight-util/ioutil: stream subkey")
+
+// newGCM以HKDF从key与流的salt派生的子密钥创建AES-256-GCM
+func newGCM(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != EncryptKeyBytes {
+		return nil, ErrInvalidKeySize
+	}
+	block, err := aes.NewCipher(hkdfSHA256(key, salt, encryptHKDFInfo, EncryptKeyBytes))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// hkdfSHA256按RFC 5869从key与salt派生长度为n的密钥
+func hkdfSHA256(key, salt, info []byte, n int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(key)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+
+	var out, t []byte
+	for c := byte(1); len(out) < n; c++ {
+		expand.Reset()
+		expand.Write(t)
+		expand.Write(info)
+		expand.Write([]byte{c})
+		t = expand.Sum(t[:0])
+		out = append(out, t...)
+	}
+	return out[:n]
+}
+
+// chunkNonce按counter和last标记生成chunk的nonce
+func chunkNonce(nonce []byte, prefix []byte, counter uint32, last bool) []byte {
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encryptPrefixBytes:], counter)
+	nonce[len(nonce)-1] = 0
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	nonce   []byte
+	counter uint32
+	buf     []byte // 待加密的明文，最多EncryptChunkBytes
+	out     []byte
+	err     error
+}
+
+// NewEncryptWriter返回一个用key(32 bytes)加密并写入w的WriteCloser，keyID记录在header中
+// 最后一个chunk在Close时写出，Close不关闭w
+// w可以是PageWriter、文件等任意io.Writer
+func NewEncryptWriter(w io.Writer, keyID string, key []byte) (io.WriteCloser, error) {
+	if len(keyID) > 255 {
+		return nil, fmt.Errorf("ioutil: key id too long (%d bytes)", len(keyID))
+	}
+	if len(key) != EncryptKeyBytes {
+		return nil, ErrInvalidKeySize
+	}
+
+	hdr := make([]byte, encryptFixedBytes, encryptFixedBytes+len(keyID))
+	copy(hdr, encryptMagic)
+	hdr[4] = encryptVersion
+	binary.BigEndian.PutUint32(hdr[5:9], EncryptChunkBytes)
+	// salt与noncePrefix
+	salt, prefix := hdr[9:9+encryptSaltBytes], hdr[9+encryptSaltBytes:encryptFixedBytes-1]
+	if _, err := io.ReadFull(rand.Reader, hdr[9:encryptFixedBytes-1]); err != nil {
+		return nil, err
+	}
+	hdr[encryptFixedBytes-1] = byte(len(keyID))
+	hdr = append(hdr, keyID...)
+
+	aead, err := newGCM(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:      w,
+		aead:   aead,
+		header: hdr,
+		prefix: prefix,
+		nonce:  make([]byte, aead.NonceSize()),
+		buf:    make([]byte, 0, EncryptChunkBytes),
+		out:    make([]byte, 0, EncryptChunkBytes+aead.Overhead()),
+	}, nil
+}
+
+func (ew *encryptWriter) Write(p []byte) (n int, err error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+	for len(p) > 0 {
+		// 满chunk在确认还有后续数据时才写出，最后一个chunk留给Close
+		if len(ew.buf) == cap(ew.buf) {
+			if err = ew.seal(false); err != nil {
+				return n, err
+			}
+		}
+		c := copy(ew.buf[len(ew.buf):cap(ew.buf)], p)
+		ew.buf = ew.buf[:len(ew.buf)+c]
+		p = p[c:]
+		n += c
+	}
+	return n, nil
+}
+
+func (ew *encryptWriter) seal(last bool) error {
+	nonce := chunkNonce(ew.nonce, ew.prefix, ew.counter, last)
+	ew.out = ew.aead.Seal(ew.out[:0], nonce, ew.buf, ew.header)
+	if _, err := ew.w.Write(ew.out); err != nil {
+		ew.err = err
+		return err
+	}
+	ew.buf = ew.buf[:0]
+	if ew.counter == ^uint32(0) {
+		ew.err = ErrEncryptStreamLimit
+		return ew.err
+	}
+	ew.counter++
+	return nil
+}
+
+// Close写出最后一个chunk(可能为空)
+func (ew *encryptWriter) Close() error {
+	if ew.err != nil {
+		if ew.err == ErrEncryptWriterClosed {
+			return nil
+		}
+		return ew.err
+	}
+	if err := ew.seal(true); err != nil && err != ErrEncryptStreamLimit {
+		return err
+	}
+	ew.err = ErrEncryptWriterClosed
+	return nil
+}
+
+type decryptReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	nonce   []byte
+	counter uint32
+	in      []byte
+	out     []byte // 与in分开: Open认证失败时会清零dst
+	plain   []byte // 已解密但未读取的明文
+	done    bool
+	err     error
+}
+
+// NewDecryptReader读取header，通过keys获取header中key ID对应的密钥，并逐chunk解密认证r
+// chunk被篡改或重排时Read返回ErrDecrypt，流在chunk边界被截断时返回ErrTruncatedStream
+// 在chunk中间被截断与篡改无法区分，返回ErrDecrypt
+func NewDecryptReader(r io.Reader, keys KeyFunc) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	fixed := make([]byte, encryptFixedBytes)
+	if _, err := io.ReadFull(br, fixed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBadEncryptHeader
+		}
+		return nil, err
+	}
+	if !bytes.Equal(fixed[:4], encryptMagic) || fixed[4] != encryptVersion {
+		return nil, ErrBadEncryptHeader
+	}
+	chunkBytes := binary.BigEndian.Uint32(fixed[5:9])
+	if chunkBytes == 0 || chunkBytes > 16*1024*1024 {
+		return nil, ErrBadEncryptHeader
+	}
+	keyID := make([]byte, fixed[encryptFixedBytes-1])
+	if _, err := io.ReadFull(br, keyID); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, ErrBadEncryptHeader
+		}
+		return nil, err
+	}
+
+	key, err := keys(string(keyID))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(key, fixed[9:9+encryptSaltBytes])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:      br,
+		aead:   aead,
+		header: append(fixed, keyID...),
+		prefix: fixed[9+encryptSaltBytes : encryptFixedBytes-1],
+		nonce:  make([]byte, aead.NonceSize()),
+		in:     make([]byte, int(chunkBytes)+aead.Overhead()),
+		out:    make([]byte, 0, chunkBytes),
+	}, nil
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.plain) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		if dr.done {
+			return 0, io.EOF
+		}
+		dr.err = dr.open()
+	}
+	n := copy(p, dr.plain)
+	dr.plain = dr.plain[n:]
+	return n, nil
+}
+
+// open读取并解密下一个chunk 不满的chunk或者其后没有数据的chunk必须带last标记
+func (dr *decryptReader) open() error {
+	n, err := io.ReadFull(dr.r, dr.in)
+	last := false
+	switch err {
+	case nil:
+		if _, perr := dr.r.Peek(1); perr == io.EOF {
+			last = true
+		} else if perr != nil {
+			return perr
+		}
+	case io.EOF:
+		return ErrTruncatedStream
+	case io.ErrUnexpectedEOF:
+		last = true
+	default:
+		return err
+	}
+	if n < dr.aead.Overhead() {
+		return ErrTruncatedStream
+	}
+
+	nonce := chunkNonce(dr.nonce, dr.prefix, dr.counter, last)
+	plain, err := dr.aead.Open(dr.out[:0], nonce, dr.in[:n], dr.header)
+	if err != nil {
+		if last {
+			// 流在完整的中间chunk后被截断时，last标记不匹配
+			if _, oerr := dr.aead.Open(dr.out[:0], chunkNonce(dr.nonce, dr.prefix, dr.counter, false), dr.in[:n], dr.header); oerr == nil {
+				return ErrTruncatedStream
+			}
+		}
+		return ErrDecrypt
+	}
+	if last {
+		dr.done = true
+	} else {
+		if dr.counter == ^uint32(0) {
+			return ErrEncryptStreamLimit
+		}
+		dr.counter++
+	}
+	dr.plain = plain
+	return nil
+}
+
+// WriteAndSyncEncryptedFile与WriteAndSyncFile类似，data用key加密后写入并同步filename
+func WriteAndSyncEncryptedFile(filename string, data []byte, perm os.FileMode, keyID string, key []byte) error {
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(data)/EncryptChunkBytes*16 + 64 + len(keyID))
+	ew, err := NewEncryptWriter(&buf, keyID, key)
+	if err != nil {
+		return err
+	}
+	if _, err = ew.Write(data); err != nil {
+		return err
+	}
+	if err = ew.Close(); err != nil {
+		return err
+	}
+	return WriteAndSyncFile(filename, buf.Bytes(), perm)
+}
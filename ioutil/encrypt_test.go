@@ -0,0 +1,181 @@
+package ioutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	testKey1 = bytes.Repeat([]byte{1}, EncryptKeyBytes)
+	testKey2 = bytes.Repeat([]byte{2}, EncryptKeyBytes)
+)
+
+func testKeys(keyID string) ([]byte, error) {
+	switch keyID {
+	case "k1":
+		return testKey1, nil
+	case "k2":
+		return testKey2, nil
+	}
+	return nil, errors.New("unknown key " + keyID)
+}
+
+func encryptBytes(t *testing.T, keyID string, key, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := NewEncryptWriter(&buf, keyID, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptBytes(enc []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(enc), testKeys)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
+
+func testData(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * 31)
+	}
+	return b
+}
+
+func TestHKDFSHA256(t *testing.T) {
+	// RFC 5869 A.1
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	want := "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"
+	if got := hex.EncodeToString(hkdfSHA256(ikm, salt, info, 42)); got != want {
+		t.Errorf("okm = %s, want %s", got, want)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, EncryptChunkBytes - 1, EncryptChunkBytes, EncryptChunkBytes + 1, 3 * EncryptChunkBytes} {
+		data := testData(n)
+		// 经过PageWriter写入，且分多次Write
+		var buf bytes.Buffer
+		pw := NewPageWriter(&buf, 4096, 0)
+		w, err := NewEncryptWriter(pw, "k1", testKey1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data[:n/3])
+		w.Write(data[n/3:])
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err = pw.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := decryptBytes(buf.Bytes())
+		if err != nil {
+			t.Fatalf("size %d: err = %v", n, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("size %d: plaintext mismatch", n)
+		}
+	}
+}
+
+func TestEncryptStreamsUseDistinctSubkeys(t *testing.T) {
+	data := testData(64)
+	a, b := encryptBytes(t, "k1", testKey1, data), encryptBytes(t, "k1", testKey1, data)
+	if bytes.Equal(a[encryptFixedBytes+2:], b[encryptFixedBytes+2:]) {
+		t.Error("two streams under the same key produced identical ciphertext")
+	}
+}
+
+func TestDecryptDetectsTampering(t *testing.T) {
+	enc := encryptBytes(t, "k1", testKey1, testData(3*EncryptChunkBytes))
+	hdr := encryptFixedBytes + 2
+	chunk := EncryptChunkBytes + 16
+
+	swapped := append([]byte(nil), enc...)
+	copy(swapped[hdr:], enc[hdr+chunk:hdr+2*chunk])
+	copy(swapped[hdr+chunk:], enc[hdr:hdr+chunk])
+
+	// 改变header中的key ID，两个key相同时也必须认证失败
+	sameKey := func(string) ([]byte, error) { return testKey1, nil }
+	renamed := append([]byte(nil), enc...)
+	renamed[hdr-1] = '2'
+
+	tests := []struct {
+		name string
+		data []byte
+		keys KeyFunc
+		werr error
+	}{
+		{"reordered", swapped, testKeys, ErrDecrypt},
+		{"truncated at chunk boundary", enc[:hdr+2*chunk], testKeys, ErrTruncatedStream},
+		{"truncated mid chunk", enc[:hdr+chunk+100], testKeys, ErrDecrypt},
+		{"header only", enc[:hdr], testKeys, ErrTruncatedStream},
+		{"payload bit flip", flip(enc, hdr+10), testKeys, ErrDecrypt},
+		{"salt bit flip", flip(enc, 9), testKeys, ErrDecrypt},
+		{"nonce prefix bit flip", flip(enc, 9+encryptSaltBytes), testKeys, ErrDecrypt},
+		{"key id changed", renamed, sameKey, ErrDecrypt},
+		{"wrong key", renamed, testKeys, ErrDecrypt},
+		{"bad magic", flip(enc, 0), testKeys, ErrBadEncryptHeader},
+	}
+	for _, tt := range tests {
+		r, err := NewDecryptReader(bytes.NewReader(tt.data), tt.keys)
+		if err == nil {
+			_, err = io.Copy(ioutil.Discard, r)
+		}
+		if err != tt.werr {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.werr)
+		}
+	}
+
+	if _, err := NewDecryptReader(bytes.NewReader(encryptBytes(t, "k3", testKey1, nil)), testKeys); err == nil {
+		t.Error("unknown key id: err = nil")
+	}
+}
+
+func TestEncryptKeyRotation(t *testing.T) {
+	old := encryptBytes(t, "k1", testKey1, []byte("old"))
+	cur := encryptBytes(t, "k2", testKey2, []byte("new"))
+	for _, tt := range []struct{ enc, want []byte }{{old, []byte("old")}, {cur, []byte("new")}} {
+		got, err := decryptBytes(tt.enc)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("got %q, %v, want %q", got, err, tt.want)
+		}
+	}
+	if _, err := NewEncryptWriter(ioutil.Discard, "k1", []byte("short")); err != ErrInvalidKeySize {
+		t.Errorf("err = %v, want %v", err, ErrInvalidKeySize)
+	}
+}
+
+func TestWriteAndSyncEncryptedFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "snap")
+	data := testData(EncryptChunkBytes + 10)
+	if err := WriteAndSyncEncryptedFile(p, data, 0600, "k2", testKey2); err != nil {
+		t.Fatal(err)
+	}
+	enc, err := ioutil.ReadFile(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decryptBytes(enc)
+	if err != nil || !bytes.Equal(got, data) {
+		t.Errorf("err = %v, plaintext match = %v", err, bytes.Equal(got, data))
+	}
+}
@@ -2,10 +2,22 @@ package ioutil
 
 import (
 	"io"
+	"sync/atomic"
+	"time"
 )
 
 var defaultBufferBytes = 128 * 1024
 
+// 底层写入延迟直方图的默认桶上界
+var defaultLatencyBounds = []time.Duration{
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
 // PageWriter实现io.writer
 type PageWriter struct {
 	w                 io.Writer
@@ -14,16 +26,78 @@ type PageWriter struct {
 	bufferedBytes     int    // buffer中pending的byte数
 	buf               []byte // 写buffer
 	bufWatermarkBytes int    // 在执行flush之前buffer中byte的数量 小于len(buf),需要slack有空间来完成write操作，并能够保证页面对齐
+
+	stats *pageWriterCounters // 单独分配，保证32位平台上原子操作的int64按8 bytes对齐
+}
+
+// PageWriterOptions配置PageWriter 零值字段使用默认值
+type PageWriterOptions struct {
+	// buffer大小(不含用于页面对齐的slack)，默认128KiB
+	BufferBytes int
+	// buffer中超过该byte数时flush，默认等于BufferBytes，不能大于BufferBytes
+	WatermarkBytes int
+	// 底层写入延迟直方图的桶上界(升序)，默认10us~1s
+	LatencyBounds []time.Duration
+}
+
+// PageWriterStats是PageWriter统计信息的快照
+type PageWriterStats struct {
+	BufferedBytes      int64 // 经过buffer写入的byte数
+	DirectWrites       int64 // 绕过buffer直接写入整页的次数
+	DirectBytes        int64 // 绕过buffer直接写入的byte数
+	Flushes            int64 // buffer flush的次数
+	PartialPageFlushes int64 // flush结束位置不在页边界的次数
+	WriteLatency       LatencyHistogram
+}
+
+// LatencyHistogram是底层io.Writer写入延迟的直方图
+// Counts[i]为延迟不超过Bounds[i]的次数(不含更小的桶)，最后一个元素为超过所有上界的次数
+type LatencyHistogram struct {
+	Bounds []time.Duration
+	Counts []uint64
+	Sum    time.Duration
+}
+
+type pageWriterCounters struct {
+	bufferedBytes      int64
+	directWrites       int64
+	directBytes        int64
+	flushes            int64
+	partialPageFlushes int64
+	latencySum         int64
+	latencyBounds      []time.Duration
+	latencyCounts      []uint64
 }
 
 // 创建PageWriter
 func NewPageWriter(w io.Writer, pageBytes, pageOffset int) *PageWriter {
+	return NewPageWriterWithOptions(w, pageBytes, pageOffset, PageWriterOptions{})
+}
+
+// NewPageWriterWithOptions按opts创建PageWriter，用于针对不同的磁盘调整buffer大小与flush水位
+func NewPageWriterWithOptions(w io.Writer, pageBytes, pageOffset int, opts PageWriterOptions) *PageWriter {
+	bufferBytes := opts.BufferBytes
+	if bufferBytes <= 0 {
+		bufferBytes = defaultBufferBytes
+	}
+	watermark := opts.WatermarkBytes
+	if watermark <= 0 || watermark > bufferBytes {
+		watermark = bufferBytes
+	}
+	bounds := opts.LatencyBounds
+	if len(bounds) == 0 {
+		bounds = defaultLatencyBounds
+	}
 	return &PageWriter{
 		w:                 w,
 		pageOffset:        pageOffset,
 		pageBytes:         pageBytes,
-		buf:               make([]byte, defaultBufferBytes+pageBytes),
-		bufWatermarkBytes: defaultBufferBytes,
+		buf:               make([]byte, bufferBytes+pageBytes),
+		bufWatermarkBytes: watermark,
+		stats: &pageWriterCounters{
+			latencyBounds: append([]time.Duration(nil), bounds...),
+			latencyCounts: make([]uint64, len(bounds)+1),
+		},
 	}
 }
 
@@ -35,6 +109,7 @@ func (pw *PageWriter) Write(p []byte) (n int, err error) {
 	if len(p)+pw.bufferedBytes <= pw.bufWatermarkBytes {
 		copy(pw.buf[pw.bufferedBytes:], p)
 		pw.bufferedBytes += len(p)
+		atomic.AddInt64(&pw.stats.bufferedBytes, int64(len(p)))
 		return len(p), nil
 	}
 
@@ -49,6 +124,7 @@ func (pw *PageWriter) Write(p []byte) (n int, err error) {
 		}
 		copy(pw.buf[pw.bufferedBytes:], p[:slack]) // 将p中byte写入buffer
 		pw.bufferedBytes += slack
+		atomic.AddInt64(&pw.stats.bufferedBytes, int64(slack))
 		n = slack
 		p = p[slack:] // 获取p中剩下的byte
 		if partial {
@@ -56,16 +132,18 @@ func (pw *PageWriter) Write(p []byte) (n int, err error) {
 		}
 	}
 	if err = pw.Flush(); err != nil { // 页面对齐， 刷盘；clean buffer
-		return n, nil
+		return n, err
 	}
 
 	// 当write的bytes超过page bytes 采用直接写
 	if len(p) > pw.pageBytes { // 直接写
 		pages := len(p) / pw.pageBytes
-		c, werr := pw.w.Write(p[:pages*pw.pageBytes])
+		c, werr := pw.write(p[:pages*pw.pageBytes])
 		n += c
+		atomic.AddInt64(&pw.stats.directWrites, 1)
+		atomic.AddInt64(&pw.stats.directBytes, int64(c))
 		if werr != nil {
-			return n, nil
+			return n, werr
 		}
 		p = p[pages*pw.pageBytes:]
 	}
@@ -79,8 +157,49 @@ func (pw *PageWriter) Flush() error {
 	if pw.bufferedBytes == 0 {
 		return nil
 	}
-	_, err := pw.w.Write(pw.buf[:pw.bufferedBytes])
+	_, err := pw.write(pw.buf[:pw.bufferedBytes])
+	atomic.AddInt64(&pw.stats.flushes, 1)
+	if (pw.pageOffset+pw.bufferedBytes)%pw.pageBytes != 0 {
+		atomic.AddInt64(&pw.stats.partialPageFlushes, 1)
+	}
 	pw.pageOffset = (pw.pageOffset + pw.bufferedBytes) % pw.pageBytes // 当前page的offset
-	pw.bufferedBytes = 0  // 执行flush之后  clean buffer
+	pw.bufferedBytes = 0                                              // 执行flush之后  clean buffer
 	return err
 }
+
+// Stats返回统计信息的快照，可以与Write并发调用
+func (pw *PageWriter) Stats() PageWriterStats {
+	s := pw.stats
+	h := LatencyHistogram{
+		Bounds: append([]time.Duration(nil), s.latencyBounds...),
+		Counts: make([]uint64, len(s.latencyCounts)),
+		Sum:    time.Duration(atomic.LoadInt64(&s.latencySum)),
+	}
+	for i := range s.latencyCounts {
+		h.Counts[i] = atomic.LoadUint64(&s.latencyCounts[i])
+	}
+	return PageWriterStats{
+		BufferedBytes:      atomic.LoadInt64(&s.bufferedBytes),
+		DirectWrites:       atomic.LoadInt64(&s.directWrites),
+		DirectBytes:        atomic.LoadInt64(&s.directBytes),
+		Flushes:            atomic.LoadInt64(&s.flushes),
+		PartialPageFlushes: atomic.LoadInt64(&s.partialPageFlushes),
+		WriteLatency:       h,
+	}
+}
+
+// write写入底层io.Writer并记录延迟
+func (pw *PageWriter) write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := pw.w.Write(p)
+	d := time.Since(start)
+
+	s := pw.stats
+	atomic.AddInt64(&s.latencySum, int64(d))
+	i := 0
+	for i < len(s.latencyBounds) && d > s.latencyBounds[i] {
+		i++
+	}
+	atomic.AddUint64(&s.latencyCounts[i], 1)
+	return n, err
+}
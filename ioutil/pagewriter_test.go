@@ -0,0 +1,73 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPageWriterStats(t *testing.T) {
+	var buf bytes.Buffer
+	pw := NewPageWriterWithOptions(&buf, 8, 0, PageWriterOptions{
+		BufferBytes:   32,
+		LatencyBounds: []time.Duration{time.Hour},
+	})
+
+	data := make([]byte, 50)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	// 10 bytes进入buffer；之后的40 bytes先补齐页(6 bytes)并flush，整页部分(32 bytes)直接写入，剩余2 bytes进入buffer
+	if n, err := pw.Write(data[:10]); n != 10 || err != nil {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if n, err := pw.Write(data[10:]); n != 40 || err != nil {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	if err := pw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Errorf("output = %v, want %v", buf.Bytes(), data)
+	}
+
+	s := pw.Stats()
+	want := PageWriterStats{
+		BufferedBytes:      18,
+		DirectWrites:       1,
+		DirectBytes:        32,
+		Flushes:            2,
+		PartialPageFlushes: 1,
+	}
+	if s.BufferedBytes != want.BufferedBytes || s.DirectWrites != want.DirectWrites || s.DirectBytes != want.DirectBytes ||
+		s.Flushes != want.Flushes || s.PartialPageFlushes != want.PartialPageFlushes {
+		t.Errorf("stats = %+v, want %+v", s, want)
+	}
+	if h := s.WriteLatency; len(h.Counts) != 2 || h.Counts[0] != 3 || h.Counts[1] != 0 {
+		t.Errorf("latency histogram = %+v, want 3 writes in the first bucket", h)
+	}
+}
+
+func TestPageWriterWriteError(t *testing.T) {
+	werr := errors.New("write failed")
+
+	// flush buffer时出错
+	pw := NewPageWriterWithOptions(&failingWriter{err: werr}, 8, 0, PageWriterOptions{BufferBytes: 16})
+	pw.Write(make([]byte, 12))
+	if _, err := pw.Write(make([]byte, 12)); err != werr {
+		t.Errorf("flush: err = %v, want %v", err, werr)
+	}
+
+	// 直接写入整页时出错
+	pw = NewPageWriterWithOptions(&failingWriter{err: werr}, 8, 0, PageWriterOptions{BufferBytes: 16})
+	if _, err := pw.Write(make([]byte, 64)); err != werr {
+		t.Errorf("direct write: err = %v, want %v", err, werr)
+	}
+
+	pw = NewPageWriterWithOptions(&failingWriter{err: werr}, 8, 0, PageWriterOptions{BufferBytes: 16})
+	pw.Write(make([]byte, 3))
+	if err := pw.Flush(); err != werr {
+		t.Errorf("Flush: err = %v, want %v", err, werr)
+	}
+}
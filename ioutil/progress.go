@@ -0,0 +1,188 @@
+package ioutil
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+const copyBufferBytes = 32 * 1024
+
+// CopyContext与io.Copy相同，但ctx结束时返回已拷贝的byte数与ctx.Err()
+// 为了打断阻塞中的Read/Write，ctx结束时对src与dst设置已过期的deadline(实现了SetDeadline，例如net.Conn、os.Pipe)，
+// 返回前清除deadline；src与dst不会被关闭，不支持deadline时只能在下一次读写之前返回
+// 拷贝已经完成时返回拷贝本身的结果
+func CopyContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	if ctx.Done() == nil {
+		return copyContext(ctx, dst, src)
+	}
+
+	var interrupted []deadliner
+	stop, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		select {
+		case <-ctx.Done():
+			for _, v := range []interface{}{src, dst} {
+				if d, ok := v.(deadliner); ok && d.SetDeadline(time.Unix(1, 0)) == nil {
+					interrupted = append(interrupted, d)
+				}
+			}
+		case <-stop:
+		}
+	}()
+
+	written, err := copyContext(ctx, dst, src)
+	close(stop)
+	<-exited
+	for _, d := range interrupted {
+		d.SetDeadline(time.Time{})
+	}
+	if err != nil && ctx.Err() != nil {
+		return written, ctx.Err()
+	}
+	return written, err
+}
+
+func copyContext(ctx context.Context, dst io.Writer, src io.Reader) (int64, error) {
+	buf := make([]byte, copyBufferBytes)
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		nr, rerr := src.Read(buf)
+		if nr > 0 {
+			nw, werr := dst.Write(buf[:nr])
+			written += int64(nw)
+			if werr != nil {
+				return written, werr
+			}
+			if nw != nr {
+				return written, io.ErrShortWrite
+			}
+		}
+		if rerr == io.EOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, rerr
+		}
+	}
+}
+
+type deadliner interface {
+	SetDeadline(t time.Time) error
+}
+
+// Progress是一次进度报告
+type Progress struct {
+	Bytes       int64         // 已传输的byte数
+	Total       int64         // 总byte数，0表示未知
+	Elapsed     time.Duration // 从开始到现在的时间
+	BytesPerSec float64       // 平均吞吐
+	ETA         time.Duration // 预计剩余时间，Total未知或者尚无吞吐时为-1
+}
+
+// ProgressFunc接收进度报告
+type ProgressFunc func(Progress)
+
+// progressTracker按interval节流调用fn
+type progressTracker struct {
+	total    int64
+	interval time.Duration
+	fn       ProgressFunc
+
+	n          int64
+	start      time.Time
+	lastReport time.Time
+	done       bool
+}
+
+func newProgressTracker(total int64, interval time.Duration, fn ProgressFunc) *progressTracker {
+	now := time.Now()
+	return &progressTracker{total: total, interval: interval, fn: fn, start: now, lastReport: now}
+}
+
+func (t *progressTracker) add(n int) {
+	t.n += int64(n)
+	now := time.Now()
+	if t.total > 0 && t.n >= t.total {
+		t.finish(now)
+		return
+	}
+	if now.Sub(t.lastReport) >= t.interval {
+		t.report(now)
+	}
+}
+
+// finish报告最终进度 只报告一次
+func (t *progressTracker) finish(now time.Time) {
+	if t.done {
+		return
+	}
+	t.done = true
+	t.report(now)
+}
+
+func (t *progressTracker) report(now time.Time) {
+	t.lastReport = now
+	p := Progress{Bytes: t.n, Total: t.total, Elapsed: now.Sub(t.start), ETA: -1}
+	if secs := p.Elapsed.Seconds(); secs > 0 {
+		p.BytesPerSec = float64(t.n) / secs
+	}
+	if t.total > 0 && p.BytesPerSec > 0 {
+		remain := t.total - t.n
+		if remain < 0 {
+			remain = 0
+		}
+		p.ETA = time.Duration(float64(remain) / p.BytesPerSec * float64(time.Second))
+	}
+	t.fn(p)
+}
+
+// ProgressReader每隔interval通过回调报告读取进度，读到EOF或者达到total时报告最终进度
+type ProgressReader struct {
+	r io.Reader
+	t *progressTracker
+}
+
+// NewProgressReader创建ProgressReader total未知时传0
+func NewProgressReader(r io.Reader, total int64, interval time.Duration, fn ProgressFunc) *ProgressReader {
+	return &ProgressReader{r: r, t: newProgressTracker(total, interval, fn)}
+}
+
+func (pr *ProgressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if n > 0 {
+		pr.t.add(n)
+	}
+	if err == io.EOF {
+		pr.t.finish(time.Now())
+	}
+	return n, err
+}
+
+// ProgressWriter每隔interval通过回调报告写入进度，达到total或者调用Flush时报告最终进度
+type ProgressWriter struct {
+	w io.Writer
+	t *progressTracker
+}
+
+// NewProgressWriter创建ProgressWriter total未知时传0
+func NewProgressWriter(w io.Writer, total int64, interval time.Duration, fn ProgressFunc) *ProgressWriter {
+	return &ProgressWriter{w: w, t: newProgressTracker(total, interval, fn)}
+}
+
+func (pw *ProgressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	if n > 0 {
+		pw.t.add(n)
+	}
+	return n, err
+}
+
+// Flush立即报告当前进度，每次调用都会报告，用于total未知时在传输结束后报告最终进度
+func (pw *ProgressWriter) Flush() {
+	pw.t.report(time.Now())
+}
@@ -0,0 +1,136 @@
+package ioutil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestCopyContext(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 3*copyBufferBytes+5)
+	var dst bytes.Buffer
+	n, err := CopyContext(context.Background(), &dst, bytes.NewReader(data))
+	if err != nil || n != int64(len(data)) || !bytes.Equal(dst.Bytes(), data) {
+		t.Errorf("n = %d, err = %v", n, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = CopyContext(ctx, ioutil.Discard, bytes.NewReader(data)); err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCopyContextInterruptsBlockedIO(t *testing.T) {
+	// src阻塞在Read上
+	r1, r2 := net.Pipe()
+	defer r1.Close()
+	defer r2.Close()
+	// dst阻塞在Write上
+	w1, w2 := net.Pipe()
+	defer w1.Close()
+	defer w2.Close()
+
+	tests := []struct {
+		name string
+		dst  io.Writer
+		src  io.Reader
+		conn net.Conn
+		peer net.Conn
+	}{
+		{"blocked read", ioutil.Discard, r1, r1, r2},
+		{"blocked write", w1, bytes.NewReader(make([]byte, 1024)), w1, w2},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		start := time.Now()
+		_, err := CopyContext(ctx, tt.dst, tt.src)
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s: returned after %v", tt.name, elapsed)
+		}
+
+		// deadline已被清除，连接没有被关闭
+		go tt.peer.Write([]byte("x"))
+		if _, err = tt.conn.Read(make([]byte, 1)); err != nil {
+			t.Errorf("%s: read after CopyContext: %v", tt.name, err)
+		}
+	}
+}
+
+// cancelReader在返回EOF时结束ctx
+type cancelReader struct {
+	io.Reader
+	cancel context.CancelFunc
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		r.cancel()
+	}
+	return n, err
+}
+
+func TestCopyContextCancelAfterCopy(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := &cancelReader{Reader: bytes.NewReader([]byte("done")), cancel: cancel}
+	if n, err := CopyContext(ctx, ioutil.Discard, src); n != 4 || err != nil {
+		t.Errorf("n = %d, err = %v, want 4, nil", n, err)
+	}
+}
+
+func TestCopyContextLeavesFilesOpen(t *testing.T) {
+	f, err := ioutil.TempFile(t.TempDir(), "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	f.WriteString("data")
+	f.Seek(0, io.SeekStart)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = CopyContext(ctx, ioutil.Discard, f); err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if _, err = f.Read(make([]byte, 4)); err != nil {
+		t.Errorf("read after CopyContext: %v", err)
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	var reports []Progress
+	r := NewProgressReader(bytes.NewReader(make([]byte, 1000)), 1000, time.Hour, func(p Progress) {
+		reports = append(reports, p)
+	})
+	if _, err := io.Copy(ioutil.Discard, NewLimitedBufferReader(r, 100)); err != nil {
+		t.Fatal(err)
+	}
+	// interval很长，只有达到total时报告一次
+	if len(reports) != 1 || reports[0].Bytes != 1000 || reports[0].Total != 1000 || reports[0].ETA != 0 {
+		t.Errorf("reports = %+v", reports)
+	}
+}
+
+func TestProgressWriterFlush(t *testing.T) {
+	var reports []Progress
+	w := NewProgressWriter(ioutil.Discard, 0, time.Hour, func(p Progress) {
+		reports = append(reports, p)
+	})
+	w.Write(make([]byte, 10))
+	w.Flush()
+	w.Write(make([]byte, 5))
+	w.Flush()
+	if len(reports) != 2 || reports[0].Bytes != 10 || reports[1].Bytes != 15 || reports[1].ETA != -1 {
+		t.Errorf("reports = %+v", reports)
+	}
+}
@@ -0,0 +1,189 @@
+package ioutil
+
+import (
+	"context"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimiter是按byte计数的令牌桶，可以被多个Reader/Writer共享
+// 令牌以每秒limit个的速度产生，最多积累burst个
+type RateLimiter struct {
+	mu        sync.Mutex
+	limit     float64 // 每秒产生的令牌数，<=0表示不限速
+	burst     int
+	autoBurst bool // burst由limit推导(1秒的流量)，SetLimit时重新计算
+	tokens    float64
+	last      time.Time
+	changed   chan struct{} // SetLimit/SetBurst时关闭，唤醒正在等待的调用者
+}
+
+// NewRateLimiter创建每秒bytesPerSec个byte、桶容量为burst的限速器
+// bytesPerSec<=0表示不限速；burst<=0时取1秒的流量
+func NewRateLimiter(bytesPerSec int64, burst int) *RateLimiter {
+	l := &RateLimiter{last: time.Now(), changed: make(chan struct{})}
+	l.set(bytesPerSec, burst)
+	l.tokens = float64(l.burst)
+	return l
+}
+
+// SetLimit在运行时修改限速，正在等待的调用者按新的速度重新计算等待时间
+// 未显式指定burst时，burst随之调整为新的1秒流量
+func (l *RateLimiter) SetLimit(bytesPerSec int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(time.Now())
+	burst := l.burst
+	if l.autoBurst {
+		burst = 0
+	}
+	l.set(bytesPerSec, burst)
+	l.notify()
+}
+
+// SetBurst在运行时修改桶容量 burst<=0表示取1秒的流量
+func (l *RateLimiter) SetBurst(burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.advance(time.Now())
+	l.set(int64(l.limit), burst)
+	l.notify()
+}
+
+// Limit返回当前每秒的byte数，0表示不限速
+func (l *RateLimiter) Limit() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int64(l.limit)
+}
+
+// Burst返回桶容量，即单次WaitN允许的最大n
+func (l *RateLimiter) Burst() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.burst
+}
+
+func (l *RateLimiter) set(bytesPerSec int64, burst int) {
+	l.limit = float64(bytesPerSec)
+	if l.limit < 0 {
+		l.limit = 0
+	}
+	l.autoBurst = burst <= 0
+	if l.autoBurst {
+		burst = int(math.Max(l.limit, 1))
+	}
+	l.burst = burst
+	if l.tokens > float64(burst) {
+		l.tokens = float64(burst)
+	}
+}
+
+func (l *RateLimiter) notify() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// advance按经过的时间补充令牌 调用时需持有l.mu
+func (l *RateLimiter) advance(now time.Time) {
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens = math.Min(float64(l.burst), l.tokens+elapsed.Seconds()*l.limit)
+	}
+	l.last = now
+}
+
+// WaitN阻塞直到获得n个令牌或者ctx结束
+// n大于burst时按burst计算，避免永远无法满足
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	for {
+		l.mu.Lock()
+		if l.limit <= 0 {
+			l.mu.Unlock()
+			return nil
+		}
+		if n > l.burst {
+			n = l.burst
+		}
+		now := time.Now()
+		l.advance(now)
+		if l.tokens >= float64(n) {
+			l.tokens -= float64(n)
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((float64(n) - l.tokens) / l.limit * float64(time.Second))
+		changed := l.changed
+		l.mu.Unlock()
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-changed:
+			t.Stop()
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// NewRateLimitedReader返回按l限速的Reader，ctx结束时Read返回ctx.Err()
+// 与NewLimitedBufferReader只限制单次读取的大小不同，它限制的是单位时间内的流量
+func NewRateLimitedReader(ctx context.Context, r io.Reader, l *RateLimiter) io.Reader {
+	return &rateLimitedReader{ctx: ctx, r: r, l: l}
+}
+
+type rateLimitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	l   *RateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if b := r.l.Burst(); r.l.Limit() > 0 && len(p) > b {
+		p = p[:b]
+	}
+	// 读取之后按实际读到的byte数扣除令牌
+	n, err := r.r.Read(p)
+	if n > 0 {
+		if werr := r.l.WaitN(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// NewRateLimitedWriter返回按l限速的Writer，ctx结束时Write返回ctx.Err()
+func NewRateLimitedWriter(ctx context.Context, w io.Writer, l *RateLimiter) io.Writer {
+	return &rateLimitedWriter{ctx: ctx, w: w, l: l}
+}
+
+type rateLimitedWriter struct {
+	ctx context.Context
+	w   io.Writer
+	l   *RateLimiter
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := p
+		if b := w.l.Burst(); w.l.Limit() > 0 && len(chunk) > b {
+			chunk = chunk[:b]
+		}
+		if err = w.l.WaitN(w.ctx, len(chunk)); err != nil {
+			return n, err
+		}
+		c, werr := w.w.Write(chunk)
+		n += c
+		if werr != nil {
+			return n, werr
+		}
+		p = p[c:]
+	}
+	return n, nil
+}
@@ -0,0 +1,97 @@
+package ioutil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAutoBurst(t *testing.T) {
+	l := NewRateLimiter(0, 0)
+	l.SetLimit(10 << 20)
+	if b := l.Burst(); b != 10<<20 {
+		t.Fatalf("burst = %d, want %d", b, 10<<20)
+	}
+
+	l = NewRateLimiter(0, 0)
+	r := NewRateLimitedReader(context.Background(), bytes.NewReader(make([]byte, 64*1024)), l)
+	l.SetLimit(10 << 20)
+	n, err := r.Read(make([]byte, 64*1024))
+	if err != nil || n != 64*1024 {
+		t.Errorf("n = %d, err = %v, want %d", n, err, 64*1024)
+	}
+
+	// 显式指定的burst不随limit变化
+	l = NewRateLimiter(100, 10)
+	l.SetLimit(1000)
+	if b := l.Burst(); b != 10 {
+		t.Errorf("burst = %d, want 10", b)
+	}
+}
+
+func TestRateLimiterShared(t *testing.T) {
+	const (
+		rate  = 200 * 1024
+		burst = 16 * 1024
+		each  = 32 * 1024
+	)
+	l := NewRateLimiter(rate, burst)
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := NewRateLimitedWriter(context.Background(), ioutil.Discard, l)
+			if _, err := w.Write(make([]byte, each)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 共享令牌桶: 除初始的burst外，其余数据按rate写入
+	want := time.Duration(float64(2*each-burst) / rate * float64(time.Second))
+	if elapsed := time.Since(start); elapsed < want*9/10 {
+		t.Errorf("elapsed = %v, want >= %v", elapsed, want)
+	}
+}
+
+func TestRateLimiterSetLimitWakesWaiters(t *testing.T) {
+	l := NewRateLimiter(1, 1000)
+	if err := l.WaitN(context.Background(), 1000); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- l.WaitN(context.Background(), 1000) }()
+	time.Sleep(10 * time.Millisecond)
+	// 取消限速
+	l.SetLimit(0)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitN not woken by SetLimit")
+	}
+}
+
+func TestRateLimitedReaderCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l := NewRateLimiter(1, 1)
+	r := NewRateLimitedReader(ctx, bytes.NewReader(make([]byte, 10)), l)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	if _, err := io.Copy(ioutil.Discard, r); err != context.Canceled {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+}
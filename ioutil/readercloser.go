@@ -1,7 +1,10 @@
 package ioutil
 
 import (
+	"bytes"
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io"
 )
 
@@ -48,3 +51,45 @@ func (e *exactReadCloser) Close() error {
 	}
 	return nil
 }
+
+// ErrChecksumMismatch表示读取内容的摘要与期望值不一致
+var ErrChecksumMismatch = fmt.Errorf("ioutil: checksum mismatch")
+
+// NewCRC32C返回Castagnoli多项式的CRC32 hash，可用于NewVerifyingReadCloser
+// SHA-256等使用标准库，xxhash等第三方实现只需满足hash.Hash
+func NewCRC32C() hash.Hash32 {
+	return crc32.New(crcTable)
+}
+
+// NewVerifyingReadCloser在NewExactReadCloser的基础上，用h计算读取内容的摘要
+// Close时若已读取totalBytes且摘要与sum不一致，返回ErrChecksumMismatch
+func NewVerifyingReadCloser(rc io.ReadCloser, totalBytes int64, h hash.Hash, sum []byte) io.ReadCloser {
+	return &verifyingReadCloser{
+		exactReadCloser: exactReadCloser{rc: rc, totalBytes: totalBytes},
+		h:               h,
+		sum:             sum,
+	}
+}
+
+type verifyingReadCloser struct {
+	exactReadCloser
+	h   hash.Hash
+	sum []byte
+}
+
+func (v *verifyingReadCloser) Read(p []byte) (int, error) {
+	n, err := v.exactReadCloser.Read(p)
+	v.h.Write(p[:n])
+	return n, err
+}
+
+func (v *verifyingReadCloser) Close() error {
+	if err := v.exactReadCloser.Close(); err != nil {
+		return err
+	}
+
+	if v.br == v.totalBytes && !bytes.Equal(v.h.Sum(nil), v.sum) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package ioutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestVerifyingReadCloser(t *testing.T) {
+	data := []byte("verify me")
+	sha := sha256.Sum256(data)
+	crc := NewCRC32C()
+	crc.Write(data)
+
+	tests := []struct {
+		name    string
+		newHash func() hash.Hash
+		sum     []byte
+	}{
+		{"sha256", sha256.New, sha[:]},
+		{"crc32c", func() hash.Hash { return NewCRC32C() }, crc.Sum(nil)},
+	}
+	for _, tt := range tests {
+		rc := NewVerifyingReadCloser(ioutil.NopCloser(bytes.NewReader(data)), int64(len(data)), tt.newHash(), tt.sum)
+		if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if err := rc.Close(); err != nil {
+			t.Errorf("%s: close err = %v, want nil", tt.name, err)
+		}
+
+		bad := append([]byte{}, data...)
+		bad[0] ^= 1
+		rc = NewVerifyingReadCloser(ioutil.NopCloser(bytes.NewReader(bad)), int64(len(bad)), tt.newHash(), tt.sum)
+		if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if err := rc.Close(); err != ErrChecksumMismatch {
+			t.Errorf("%s: close err = %v, want %v", tt.name, err, ErrChecksumMismatch)
+		}
+	}
+
+	// 未读完时报告ErrShortRead而不是ErrChecksumMismatch
+	rc := NewVerifyingReadCloser(ioutil.NopCloser(bytes.NewReader(data)), int64(len(data)), sha256.New(), sha[:])
+	rc.Read(make([]byte, 1))
+	if err := rc.Close(); err != ErrShortRead {
+		t.Errorf("close err = %v, want %v", err, ErrShortRead)
+	}
+}
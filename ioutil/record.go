@@ -0,0 +1,233 @@
+package ioutil
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	// 记录头: 4 bytes数据长度 + 4 bytes CRC32C
+	recordHeaderBytes = 8
+	// 超过该长度的记录视为损坏，避免按错误的长度分配内存
+	maxRecordBytes = 64 * 1024 * 1024
+	// DefaultMaxTornBytes是默认允许视为torn tail的最大数据量(一页)
+	DefaultMaxTornBytes = 4096
+)
+
+var (
+	// ErrTornTail表示日志末尾存在写入一半的记录，可以在Offset处截断后继续写入
+	ErrTornTail = fmt.Errorf("ioutil: torn record at tail")
+	// ErrCorruptRecord表示日志中间的记录校验失败，之后仍有数据，无法安全截断
+	ErrCorruptRecord = fmt.Errorf("ioutil: corrupt record")
+	// ErrRecordTooLarge表示写入的记录超过maxRecordBytes
+	ErrRecordTooLarge = fmt.Errorf("ioutil: record too large")
+
+	crcTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// RecordSize返回长度为n的记录编码后占用的byte数
+func RecordSize(n int) int {
+	return recordHeaderBytes + padRecord(n)
+}
+
+func padRecord(n int) int {
+	return (n + 7) &^ 7
+}
+
+// rollCRC计算记录的滚动CRC: 以前一条记录的CRC为初值，覆盖长度与数据
+func rollCRC(prev uint32, lenb, rec []byte) uint32 {
+	return crc32.Update(crc32.Update(prev, crcTable, lenb), crcTable, rec)
+}
+
+// RecordWriter将每次Write的数据编码为一条记录:
+// | length(4 bytes LE) | crc32c(4 bytes LE) | payload | 0填充到8 bytes对齐 |
+// crc以前一条记录的crc为初值滚动计算，跨segment时以上一个segment的CRC()作为prevCRC即可串联校验
+type RecordWriter struct {
+	w   io.Writer
+	crc uint32
+	buf []byte
+}
+
+func NewRecordWriter(w io.Writer, prevCRC uint32) *RecordWriter {
+	return &RecordWriter{w: w, crc: prevCRC}
+}
+
+// Write写入一条完整的记录 返回len(rec)
+func (rw *RecordWriter) Write(rec []byte) (int, error) {
+	if len(rec) > maxRecordBytes {
+		return 0, ErrRecordTooLarge
+	}
+	size := RecordSize(len(rec))
+	if cap(rw.buf) < size {
+		rw.buf = make([]byte, size)
+	}
+	buf := rw.buf[:size]
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(rec)))
+	crc := rollCRC(rw.crc, buf[0:4], rec)
+	binary.LittleEndian.PutUint32(buf[4:8], crc)
+	n := copy(buf[recordHeaderBytes:], rec)
+	for i := recordHeaderBytes + n; i < size; i++ {
+		buf[i] = 0
+	}
+
+	if _, err := rw.w.Write(buf); err != nil {
+		return 0, err
+	}
+	rw.crc = crc
+	return len(rec), nil
+}
+
+// CRC返回最后一条记录的crc
+func (rw *RecordWriter) CRC() uint32 { return rw.crc }
+
+// RecordReader读取RecordWriter写入的记录并校验
+// 记录头中的长度可能已损坏，因此不按长度判断torn tail：
+// 非法记录起始处之后的非0数据不超过MaxTornBytes、且其中找不到后续的合法记录时视为torn tail，返回ErrTornTail；
+// 否则返回ErrCorruptRecord
+// 遇到全0的记录头且之后全部为0时视为正常结束，返回io.EOF
+type RecordReader struct {
+	r       *bufio.Reader
+	crc     uint32
+	off     int64
+	maxTorn int
+}
+
+func NewRecordReader(r io.Reader, prevCRC uint32) *RecordReader {
+	return &RecordReader{r: bufio.NewReader(r), crc: prevCRC, maxTorn: DefaultMaxTornBytes}
+}
+
+// SetMaxTornBytes设置允许视为torn tail的最大数据量，通常为一次底层写入的大小
+func (rr *RecordReader) SetMaxTornBytes(n int) {
+	if n < recordHeaderBytes {
+		n = recordHeaderBytes
+	}
+	rr.maxTorn = n
+}
+
+// Next返回下一条记录 返回的切片在下一次调用前有效
+func (rr *RecordReader) Next() ([]byte, error) {
+	var hdr [recordHeaderBytes]byte
+	n, err := io.ReadFull(rr.r, hdr[:])
+	if err == io.EOF {
+		return nil, io.EOF
+	}
+	if err == io.ErrUnexpectedEOF {
+		return nil, rr.bad(hdr[:n], true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	length := binary.LittleEndian.Uint32(hdr[0:4])
+	if length > maxRecordBytes {
+		return nil, rr.bad(hdr[:], false)
+	}
+	buf := make([]byte, padRecord(int(length)))
+	n, err = io.ReadFull(rr.r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return nil, rr.bad(append(hdr[:], buf[:n]...), true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rec := buf[:length]
+	crc := rollCRC(rr.crc, hdr[0:4], rec)
+	if crc != binary.LittleEndian.Uint32(hdr[4:8]) {
+		return nil, rr.bad(append(hdr[:], buf...), false)
+	}
+	rr.crc = crc
+	rr.off += int64(recordHeaderBytes + len(buf))
+	return rec, nil
+}
+
+// CRC返回最后一条合法记录的crc，作为下一个segment的prevCRC
+func (rr *RecordReader) CRC() uint32 { return rr.crc }
+
+// Offset返回最后一条合法记录结束的偏移，即torn tail的截断位置
+func (rr *RecordReader) Offset() int64 { return rr.off }
+
+// bad处理从Offset开始的非法数据frame(已读取的部分)
+// 若是frame及之后的数据全部为0，视为预分配空间的正常结束；
+// 若是非0数据不超过maxTorn，且无法从frame记录头中的CRC接续到之后的合法记录，视为torn tail；否则为中间损坏
+func (rr *RecordReader) bad(frame []byte, truncated bool) error {
+	corrupt := fmt.Errorf("%w at offset %d", ErrCorruptRecord, rr.off)
+
+	data := frame
+	if len(data) > rr.maxTorn {
+		if !isZero(data[rr.maxTorn:]) {
+			return corrupt
+		}
+		data = data[:rr.maxTorn]
+	} else {
+		more := make([]byte, rr.maxTorn-len(data))
+		n, err := io.ReadFull(rr.r, more)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		data = append(data, more[:n]...)
+	}
+	rest, err := restIsZero(rr.r)
+	if err != nil {
+		return err
+	}
+	if !rest {
+		return corrupt
+	}
+
+	if isZero(data) {
+		if truncated {
+			return ErrTornTail
+		}
+		return io.EOF
+	}
+	if len(frame) >= recordHeaderBytes && resync(data, binary.LittleEndian.Uint32(frame[4:8])) {
+		return corrupt
+	}
+	return ErrTornTail
+}
+
+// resync判断data中(跳过第一个记录头)是否存在以prevCRC为初值校验通过的记录
+// 长度字段损坏时记录头中的CRC通常仍然完好，之后的记录可以据此接续，说明损坏发生在日志中间
+func resync(data []byte, prevCRC uint32) bool {
+	for p := recordHeaderBytes; p+recordHeaderBytes <= len(data); p += 8 {
+		length := int(binary.LittleEndian.Uint32(data[p : p+4]))
+		end := p + recordHeaderBytes + length
+		if length > maxRecordBytes || end > len(data) || isZero(data[p:p+recordHeaderBytes]) {
+			continue
+		}
+		if rollCRC(prevCRC, data[p:p+4], data[p+recordHeaderBytes:end]) == binary.LittleEndian.Uint32(data[p+4:p+8]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// restIsZero读取r剩余的全部数据，判断是否全部为0
+func restIsZero(r io.Reader) (bool, error) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if !isZero(buf[:n]) {
+			return false, nil
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
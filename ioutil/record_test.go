@@ -0,0 +1,165 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestRecordWriteRead(t *testing.T) {
+	recs := [][]byte{[]byte("a"), {}, []byte("hello world"), bytes.Repeat([]byte("x"), 100)}
+
+	var buf bytes.Buffer
+	w := NewRecordWriter(&buf, 0)
+	for _, rec := range recs {
+		if _, err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 预分配的空间
+	buf.Write(make([]byte, 64))
+
+	r := NewRecordReader(bytes.NewReader(buf.Bytes()), 0)
+	for i, want := range recs {
+		rec, err := r.Next()
+		if err != nil {
+			t.Fatalf("#%d: err = %v", i, err)
+		}
+		if !bytes.Equal(rec, want) {
+			t.Errorf("#%d: rec = %q, want %q", i, rec, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("err = %v, want %v", err, io.EOF)
+	}
+	if r.CRC() != w.CRC() {
+		t.Errorf("crc = %x, want %x", r.CRC(), w.CRC())
+	}
+}
+
+func TestRecordReaderTornAndCorrupt(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewRecordWriter(&buf, 0)
+	for _, rec := range []string{"first", "second", "third"} {
+		if _, err := w.Write([]byte(rec)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := buf.Bytes()
+	second := int64(RecordSize(len("first")))
+
+	tests := []struct {
+		data []byte
+		werr error
+	}{
+		// 最后一条记录只写入了一半
+		{data[:len(data)-3], ErrTornTail},
+		// 最后一条记录的数据被破坏，之后全部为0
+		{append(flip(data, len(data)-8), make([]byte, 16)...), ErrTornTail},
+		// 中间的记录被破坏
+		{flip(data, int(second)+recordHeaderBytes), ErrCorruptRecord},
+	}
+	for i, tt := range tests {
+		r := NewRecordReader(bytes.NewReader(tt.data), 0)
+		var (
+			got [][]byte
+			err error
+		)
+		for {
+			var rec []byte
+			if rec, err = r.Next(); err != nil {
+				break
+			}
+			got = append(got, rec)
+		}
+		if !errors.Is(err, tt.werr) {
+			t.Errorf("#%d: err = %v, want %v", i, err, tt.werr)
+		}
+		if tt.werr == ErrTornTail {
+			if want := [][]byte{[]byte("first"), []byte("second")}; !reflect.DeepEqual(got, want) {
+				t.Errorf("#%d: records = %q, want %q", i, got, want)
+			}
+			if want := second + int64(RecordSize(len("second"))); r.Offset() != want {
+				t.Errorf("#%d: offset = %d, want %d", i, r.Offset(), want)
+			}
+		}
+	}
+}
+
+func TestRecordReaderCorruptLength(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewRecordWriter(&buf, 0)
+	var offs []int
+	for i := 0; i < 10; i++ {
+		offs = append(offs, buf.Len())
+		if _, err := w.Write(bytes.Repeat([]byte{byte('a' + i)}, i+1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data := append(buf.Bytes(), make([]byte, 128)...)
+
+	// 分别破坏第3条记录长度的低位(长度覆盖之后所有记录)与高位(长度超出上限)
+	for _, i := range []int{offs[2], offs[2] + 3} {
+		r := NewRecordReader(bytes.NewReader(flip(data, i)), 0)
+		n := 0
+		var err error
+		for ; ; n++ {
+			if _, err = r.Next(); err != nil {
+				break
+			}
+		}
+		if !errors.Is(err, ErrCorruptRecord) {
+			t.Errorf("flip %d: err = %v, want %v", i, err, ErrCorruptRecord)
+		}
+		if n != 2 || r.Offset() != int64(offs[2]) {
+			t.Errorf("flip %d: read %d records to offset %d, want 2 to %d", i, n, r.Offset(), offs[2])
+		}
+	}
+}
+
+func TestRecordReaderMaxTornBytes(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewRecordWriter(&buf, 0)
+	w.Write([]byte("ok"))
+	w.Write(bytes.Repeat([]byte("x"), 100))
+	// 最后一条记录被破坏，非0数据超过torn tail上限时视为中间损坏
+	data := flip(buf.Bytes(), buf.Len()-8)
+
+	r := NewRecordReader(bytes.NewReader(data), 0)
+	r.Next()
+	if _, err := r.Next(); err != ErrTornTail {
+		t.Errorf("err = %v, want %v", err, ErrTornTail)
+	}
+
+	r = NewRecordReader(bytes.NewReader(data), 0)
+	r.SetMaxTornBytes(64)
+	r.Next()
+	if _, err := r.Next(); !errors.Is(err, ErrCorruptRecord) {
+		t.Errorf("err = %v, want %v", err, ErrCorruptRecord)
+	}
+}
+
+func TestRecordChainedCRC(t *testing.T) {
+	var seg1, seg2 bytes.Buffer
+	w1 := NewRecordWriter(&seg1, 0)
+	w1.Write([]byte("a"))
+	w2 := NewRecordWriter(&seg2, w1.CRC())
+	w2.Write([]byte("b"))
+
+	if _, err := NewRecordReader(bytes.NewReader(seg2.Bytes()), 0).Next(); err != ErrTornTail {
+		t.Errorf("err = %v, want %v", err, ErrTornTail)
+	}
+	r1 := NewRecordReader(bytes.NewReader(seg1.Bytes()), 0)
+	r1.Next()
+	if _, err := NewRecordReader(bytes.NewReader(seg2.Bytes()), r1.CRC()).Next(); err != nil {
+		t.Errorf("err = %v, want nil", err)
+	}
+}
+
+func flip(data []byte, i int) []byte {
+	b := append([]byte(nil), data...)
+	b[i] ^= 0xff
+	return b
+}
@@ -0,0 +1,43 @@
+package ioutil
+
+import (
+	"fmt"
+	"io"
+)
+
+var (
+	ErrIncompleteWrite = fmt.Errorf("ioutil: incomplete write")
+	ErrWriteOverflow   = fmt.Errorf("ioutil: write exceeds declared size")
+)
+
+// NewExactWriteCloser与NewExactReadCloser对应：写入wc的数据必须恰好为totalBytes
+// 超出totalBytes的Write不写入任何数据并返回ErrWriteOverflow，Close时写入不足返回ErrIncompleteWrite
+func NewExactWriteCloser(wc io.WriteCloser, totalBytes int64) io.WriteCloser {
+	return &exactWriteCloser{wc: wc, totalBytes: totalBytes}
+}
+
+type exactWriteCloser struct {
+	wc         io.WriteCloser
+	bw         int64
+	totalBytes int64
+}
+
+func (e *exactWriteCloser) Write(p []byte) (int, error) {
+	if e.bw+int64(len(p)) > e.totalBytes {
+		return 0, ErrWriteOverflow
+	}
+	n, err := e.wc.Write(p)
+	e.bw += int64(n)
+	return n, err
+}
+
+func (e *exactWriteCloser) Close() error {
+	if err := e.wc.Close(); err != nil {
+		return err
+	}
+
+	if e.bw < e.totalBytes {
+		return ErrIncompleteWrite
+	}
+	return nil
+}
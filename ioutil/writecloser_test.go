@@ -0,0 +1,37 @@
+package ioutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+type nopWriteCloser struct{ bytes.Buffer }
+
+func (*nopWriteCloser) Close() error { return nil }
+
+func TestExactWriteCloser(t *testing.T) {
+	var buf nopWriteCloser
+	wc := NewExactWriteCloser(&buf, 8)
+	if n, err := wc.Write([]byte("12345")); n != 5 || err != nil {
+		t.Fatalf("n = %d, err = %v", n, err)
+	}
+	// 超出totalBytes的Write不写入任何数据
+	if n, err := wc.Write([]byte("6789")); n != 0 || err != ErrWriteOverflow {
+		t.Errorf("n = %d, err = %v, want 0, %v", n, err, ErrWriteOverflow)
+	}
+	if buf.String() != "12345" {
+		t.Errorf("written = %q, want %q", buf.String(), "12345")
+	}
+	if err := wc.Close(); err != ErrIncompleteWrite {
+		t.Errorf("close err = %v, want %v", err, ErrIncompleteWrite)
+	}
+
+	buf.Reset()
+	wc = NewExactWriteCloser(&buf, 8)
+	if _, err := wc.Write([]byte("12345678")); err != nil {
+		t.Fatal(err)
+	}
+	if err := wc.Close(); err != nil {
+		t.Errorf("close err = %v, want nil", err)
+	}
+}